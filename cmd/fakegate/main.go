@@ -0,0 +1,46 @@
+// Command fakegate serves an in-memory stand-in of Gate the acceptance tests
+// can run against:
+//
+//	go run ./cmd/fakegate -listen 127.0.0.1:8084 -applications keke-test
+//	GATE_ENDPOINT=http://127.0.0.1:8084 make testacc
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/himanhsugusain/terraform-provider-spinnaker/spinnaker/api"
+	"github.com/himanhsugusain/terraform-provider-spinnaker/spinnaker/fakegate"
+)
+
+func main() {
+	var listen, applications string
+
+	flag.StringVar(&listen, "listen", "127.0.0.1:8084", "address to serve the fake Gate on")
+	flag.StringVar(&applications, "applications", "", "comma separated applications which exist from the start, such as the ones the acceptance tests refer to")
+	flag.Parse()
+
+	gate := fakegate.New()
+	for _, name := range strings.Split(applications, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+
+		err := gate.Client().CreateApplication(api.CreateApplicationTask{
+			"application": name,
+			"job": []interface{}{map[string]interface{}{
+				"type":        "createApplication",
+				"application": map[string]interface{}{"name": name, "email": "fakegate@example.com"},
+			}},
+		})
+		if err != nil {
+			log.Fatalf("could not create application %s: %s", name, err)
+		}
+	}
+
+	fmt.Printf("fake Gate listening on http://%s\n", listen)
+	log.Fatal(http.ListenAndServe(listen, gate.Handler()))
+}
@@ -0,0 +1,26 @@
+#!/usr/bin/env bash
+
+# Record the cassettes of the acceptance tests against cmd/fakegate, so that
+# they can be refreshed without a Spinnaker install
+set -euo pipefail
+
+listen=${FAKEGATE_LISTEN:-127.0.0.1:8084}
+run=${CASSETTE_TESTS:-'TestAccResourceSourceSpinnaker(Application|Pipeline)_'}
+
+bin=$(mktemp -d)/fakegate
+go build -o "${bin}" ./cmd/fakegate
+"${bin}" -listen "${listen}" -applications keke-test &
+pid=$!
+trap 'kill ${pid}' EXIT
+
+echo "==> Waiting for the fake Gate on ${listen}..."
+for _ in $(seq 1 50); do
+    if curl -sf "http://${listen}/version" > /dev/null; then
+        break
+    fi
+    sleep 0.1
+done
+
+echo "==> Recording the cassettes of ${run}..."
+GATE_ENDPOINT="http://${listen}" RECORD=true TF_ACC=1 \
+    go test ./spinnaker -v -run "${run}" -timeout 30m
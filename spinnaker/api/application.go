@@ -2,15 +2,14 @@ package api
 
 import (
 	"fmt"
-	"log"
 	"net/http"
 	"regexp"
 	"strings"
 
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/mitchellh/mapstructure"
 	gate "github.com/spinnaker/spin/cmd/gateclient"
-	orca_tasks "github.com/spinnaker/spin/cmd/orca-tasks"
 	gateclient "github.com/spinnaker/spin/gateapi"
 )
 
@@ -122,11 +121,9 @@ func GetApplication(client *gate.GatewayClient, appName string, dest interface{}
 	}
 
 	if err != nil {
-		log.Println("app", app)
-		log.Println("resp", resp)
-		log.Println("err", err)
 		return err
 	}
+	tflog.SubsystemTrace(client.Context, LogFront50, "Read application", map[string]interface{}{"application": appName})
 
 	if err := mapstructure.Decode(app, dest); err != nil {
 		return err
@@ -141,7 +138,7 @@ func CreateApplication(client *gate.GatewayClient, createAppTask CreateApplicati
 	if err != nil {
 		return err
 	}
-	return orca_tasks.WaitForSuccessfulTask(client, ref)
+	return WaitForSuccessfulTask(client, ref)
 }
 
 // DeleteApplication deletes an application by application name
@@ -163,7 +160,7 @@ func DeleteApplication(client *gate.GatewayClient, appName string) error {
 	if err != nil {
 		return err
 	}
-	return orca_tasks.WaitForSuccessfulTask(client, ref)
+	return WaitForSuccessfulTask(client, ref)
 
 }
 
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// OAuth2Config is the OAuth2 client credentials grant used to obtain bearer tokens for Gate
+type OAuth2Config struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+}
+
+// newAuthTransport wraps the transport of the HTTP client with the bearer
+// token authentication configured in the provider block, if any
+func newAuthTransport(cfg ClientConfig, httpClient *http.Client) (http.RoundTripper, error) {
+	base := httpClient.Transport
+
+	configured := 0
+	for _, set := range []bool{cfg.AccessToken != "", cfg.OAuth2 != nil, cfg.Basic != nil} {
+		if set {
+			configured++
+		}
+	}
+	if configured > 1 {
+		return nil, fmt.Errorf("access_token, auth.oauth2 and auth.basic are mutually exclusive")
+	}
+
+	switch {
+	case cfg.AccessToken != "":
+		token := &oauth2.Token{AccessToken: cfg.AccessToken, TokenType: "Bearer"}
+		return &oauth2.Transport{
+			Source: oauth2.StaticTokenSource(token),
+			Base:   base,
+		}, nil
+	case cfg.OAuth2 != nil:
+		credentials := &clientcredentials.Config{
+			ClientID:     cfg.OAuth2.ClientID,
+			ClientSecret: cfg.OAuth2.ClientSecret,
+			TokenURL:     cfg.OAuth2.TokenURL,
+			Scopes:       cfg.OAuth2.Scopes,
+		}
+
+		// The token endpoint is reached with the same TLS settings as Gate.
+		// The token source caches the token and fetches a new one once it
+		// expires, which keeps long applies authenticated.
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: base})
+		source := credentials.TokenSource(ctx)
+		if _, err := source.Token(); err != nil {
+			return nil, fmt.Errorf("could not obtain an OAuth2 token from %s: %s", cfg.OAuth2.TokenURL, err)
+		}
+
+		return &oauth2.Transport{
+			Source: source,
+			Base:   base,
+		}, nil
+	case cfg.Basic != nil:
+		return newSessionTransport(cfg.Basic, cfg.GateEndpoint, httpClient)
+	}
+
+	return base, nil
+}
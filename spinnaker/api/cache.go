@@ -0,0 +1,275 @@
+package api
+
+import (
+	"context"
+	b64 "encoding/base64"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// CachedClient is a Client serving the reads of pipelines and applications
+// from a cache shared by the clients of a provider instance, which lives for
+// a single plan or apply. The pipelines of an application are read from Front50
+// at once the first time one of them is, rather than one request per
+// pipeline. Writes evict what they change, which is then read again on its own.
+type CachedClient struct {
+	Client
+
+	cache *cache
+	// user is the run as user of the client, the cache of each user is
+	// separate as their permissions may be
+	user string
+}
+
+var _ Client = &CachedClient{}
+
+// NewCachedClient returns a client caching the reads of client
+func NewCachedClient(client Client) *CachedClient {
+	return &CachedClient{
+		Client: client,
+		cache: &cache{
+			pipelines:    map[cacheKey]*pipelinesCacheEntry{},
+			applications: map[cacheKey]*applicationCacheEntry{},
+		},
+	}
+}
+
+type cache struct {
+	mu           sync.Mutex
+	pipelines    map[cacheKey]*pipelinesCacheEntry
+	applications map[cacheKey]*applicationCacheEntry
+}
+
+// cacheKey is an application as seen by a run as user
+type cacheKey struct {
+	user        string
+	application string
+}
+
+// pipelinesCacheEntry is the pipelines of an application. Its lock is held
+// while they are read, so that concurrent refreshes wait for a single read.
+type pipelinesCacheEntry struct {
+	mu     sync.Mutex
+	loaded bool
+	byName map[string]map[string]interface{}
+}
+
+type applicationCacheEntry struct {
+	mu     sync.Mutex
+	loaded bool
+	// app is nil for an application which does not exist
+	app map[string]interface{}
+}
+
+func (c *CachedClient) WithRunAsUser(user string) Client {
+	return &CachedClient{Client: c.Client.WithRunAsUser(user), cache: c.cache, user: user}
+}
+
+func (c *CachedClient) WithContext(ctx context.Context) Client {
+	return &CachedClient{Client: c.Client.WithContext(ctx), cache: c.cache, user: c.user}
+}
+
+func (c *CachedClient) GetPipeline(applicationName, pipelineName string, dest interface{}) (map[string]interface{}, error) {
+	c.cache.mu.Lock()
+	key := cacheKey{user: c.user, application: applicationName}
+	entry, ok := c.cache.pipelines[key]
+	if !ok {
+		entry = &pipelinesCacheEntry{}
+		c.cache.pipelines[key] = entry
+	}
+	c.cache.mu.Unlock()
+
+	entry.mu.Lock()
+	if !entry.loaded {
+		// When the list fails, such as when it is forbidden, the pipelines
+		// are read one by one
+		pipelines, _ := c.Client.GetPipelines(applicationName)
+		entry.loaded = true
+		entry.byName = make(map[string]map[string]interface{}, len(pipelines))
+		for _, p := range pipelines {
+			if name, ok := p["name"].(string); ok {
+				entry.byName[name] = p
+			}
+		}
+	}
+	p, ok := entry.byName[pipelineName]
+	entry.mu.Unlock()
+
+	// Pipelines missing from the list, such as the ones saved or deleted
+	// since, are read on their own, which also tells whether they exist
+	if !ok {
+		return c.Client.GetPipeline(applicationName, pipelineName, dest)
+	}
+
+	pipeline, err := cacheClone(p)
+	if err != nil {
+		return nil, err
+	}
+	return pipeline, mapstructure.Decode(pipeline, dest)
+}
+
+func (c *CachedClient) CreatePipeline(createPipeLineTask CreatePipeLineTask) error {
+	defer c.evictPipelineTask(createPipeLineTask)
+	return c.Client.CreatePipeline(createPipeLineTask)
+}
+
+func (c *CachedClient) UpdatePipeline(pipelineID string, pipeline interface{}) error {
+	defer c.evictPipelines(func(_ string, p map[string]interface{}) bool {
+		return p["id"] == pipelineID
+	})
+	return c.Client.UpdatePipeline(pipelineID, pipeline)
+}
+
+func (c *CachedClient) DeletePipeline(applicationName, pipelineName string) error {
+	defer c.evictPipelines(func(application string, p map[string]interface{}) bool {
+		return application == applicationName && p["name"] == pipelineName
+	})
+	return c.Client.DeletePipeline(applicationName, pipelineName)
+}
+
+// evictPipelines forgets the pipelines of every user which match
+func (c *CachedClient) evictPipelines(match func(application string, p map[string]interface{}) bool) {
+	c.cache.mu.Lock()
+	entries := make(map[cacheKey]*pipelinesCacheEntry, len(c.cache.pipelines))
+	for key, entry := range c.cache.pipelines {
+		entries[key] = entry
+	}
+	c.cache.mu.Unlock()
+
+	for key, entry := range entries {
+		entry.mu.Lock()
+		for name, p := range entry.byName {
+			if match(key.application, p) {
+				delete(entry.byName, name)
+			}
+		}
+		entry.mu.Unlock()
+	}
+}
+
+// evictPipelineTask forgets the pipeline saved by the task, or all the
+// pipelines of its application when the pipeline can not be told
+func (c *CachedClient) evictPipelineTask(task CreatePipeLineTask) {
+	applicationName, _ := task["application"].(string)
+	name, ok := savePipelineTaskName(task)
+	c.evictPipelines(func(application string, p map[string]interface{}) bool {
+		return application == applicationName && (!ok || p["name"] == name)
+	})
+}
+
+// savePipelineTaskName returns the name of the pipeline of a savePipeline
+// task, the pipeline of its job is base64 encoded JSON
+func savePipelineTaskName(task CreatePipeLineTask) (string, bool) {
+	var jobs []map[string]interface{}
+	switch v := task["job"].(type) {
+	case []map[string]interface{}:
+		jobs = v
+	case []interface{}:
+		for _, job := range v {
+			if job, ok := job.(map[string]interface{}); ok {
+				jobs = append(jobs, job)
+			}
+		}
+	}
+	if len(jobs) != 1 {
+		return "", false
+	}
+
+	encoded, _ := jobs[0]["pipeline"].(string)
+	b, err := b64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", false
+	}
+	var pipeline struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(b, &pipeline); err != nil || pipeline.Name == "" {
+		return "", false
+	}
+	return pipeline.Name, true
+}
+
+func (c *CachedClient) GetApplication(appName string, dest interface{}) error {
+	c.cache.mu.Lock()
+	key := cacheKey{user: c.user, application: appName}
+	entry, ok := c.cache.applications[key]
+	if !ok {
+		entry = &applicationCacheEntry{}
+		c.cache.applications[key] = entry
+	}
+	c.cache.mu.Unlock()
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	if !entry.loaded {
+		app := map[string]interface{}{}
+		err := c.Client.GetApplication(appName, &app)
+		switch {
+		case errors.Is(err, ErrCodeNoSuchEntityException):
+			entry.app = nil
+		case err != nil:
+			// Errors are not cached, the next read tries again
+			return err
+		default:
+			entry.app = app
+		}
+		entry.loaded = true
+	}
+
+	if entry.app == nil {
+		return ErrCodeNoSuchEntityException
+	}
+	app, err := cacheClone(entry.app)
+	if err != nil {
+		return err
+	}
+	return mapstructure.Decode(app, dest)
+}
+
+func (c *CachedClient) CreateApplication(createAppTask CreateApplicationTask) error {
+	applicationName, _ := createAppTask["application"].(string)
+	defer c.evictApplication(applicationName)
+	return c.Client.CreateApplication(createAppTask)
+}
+
+func (c *CachedClient) DeleteApplication(appName string) error {
+	defer c.evictApplication(appName)
+	return c.Client.DeleteApplication(appName)
+}
+
+// evictApplication forgets the application of every user, with its
+// pipelines, which Front50 deletes with it
+func (c *CachedClient) evictApplication(appName string) {
+	c.cache.mu.Lock()
+	defer c.cache.mu.Unlock()
+
+	for key := range c.cache.applications {
+		if key.application == appName {
+			delete(c.cache.applications, key)
+		}
+	}
+	for key := range c.cache.pipelines {
+		if key.application == appName {
+			delete(c.cache.pipelines, key)
+		}
+	}
+}
+
+// cacheClone returns a copy of a cached document, so that the callers can
+// not change the cache
+func cacheClone(v map[string]interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
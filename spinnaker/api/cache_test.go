@@ -0,0 +1,233 @@
+package api
+
+import (
+	"context"
+	b64 "encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// countingClient is a Client of a fixed set of pipelines and applications
+// counting its reads
+type countingClient struct {
+	Client
+
+	mu           sync.Mutex
+	pipelines    map[string][]map[string]interface{}
+	applications map[string]map[string]interface{}
+	calls        map[string]int
+}
+
+func newCountingClient() *countingClient {
+	return &countingClient{
+		pipelines: map[string][]map[string]interface{}{
+			"app": {
+				{"id": "1", "application": "app", "name": "build"},
+				{"id": "2", "application": "app", "name": "deploy"},
+				{"id": "3", "application": "app", "name": "rollback"},
+			},
+		},
+		applications: map[string]map[string]interface{}{
+			"app": {"name": "app", "attributes": map[string]interface{}{"email": "team@example.com"}},
+		},
+		calls: map[string]int{},
+	}
+}
+
+func (c *countingClient) count(call string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls[call]
+}
+
+func (c *countingClient) WithRunAsUser(user string) Client       { return c }
+func (c *countingClient) WithContext(ctx context.Context) Client { return c }
+
+func (c *countingClient) GetPipelines(applicationName string) ([]map[string]interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls["GetPipelines"]++
+
+	var pipelines []map[string]interface{}
+	for _, p := range c.pipelines[applicationName] {
+		pipelines = append(pipelines, map[string]interface{}{"id": p["id"], "application": p["application"], "name": p["name"]})
+	}
+	return pipelines, nil
+}
+
+func (c *countingClient) GetPipeline(applicationName, pipelineName string, dest interface{}) (map[string]interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls["GetPipeline"]++
+
+	for _, p := range c.pipelines[applicationName] {
+		if p["name"] == pipelineName {
+			return p, mapstructure.Decode(p, dest)
+		}
+	}
+	return nil, ErrCodeNoSuchEntityException
+}
+
+func (c *countingClient) CreatePipeline(task CreatePipeLineTask) error { return nil }
+func (c *countingClient) UpdatePipeline(pipelineID string, pipeline interface{}) error {
+	return nil
+}
+func (c *countingClient) DeletePipeline(applicationName, pipelineName string) error { return nil }
+
+func (c *countingClient) GetApplication(appName string, dest interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls["GetApplication"]++
+
+	app, ok := c.applications[appName]
+	if !ok {
+		return ErrCodeNoSuchEntityException
+	}
+	return mapstructure.Decode(app, dest)
+}
+
+func (c *countingClient) CreateApplication(task CreateApplicationTask) error { return nil }
+
+func TestCachedClientPipelines(t *testing.T) {
+	inner := newCountingClient()
+	client := NewCachedClient(inner).WithContext(context.Background())
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 30)
+	for i := 0; i < 10; i++ {
+		for _, name := range []string{"build", "deploy", "rollback"} {
+			wg.Add(1)
+			go func(name string) {
+				defer wg.Done()
+				var p struct{ Name string }
+				if _, err := client.GetPipeline("app", name, &p); err != nil || p.Name != name {
+					errs <- fmt.Errorf("expected pipeline %s, got %v: %v", name, p, err)
+				}
+			}(name)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("failed: %v", err)
+	}
+	if got := inner.count("GetPipelines"); got != 1 {
+		t.Fatalf("expected the pipelines to be listed once, got %d", got)
+	}
+	if got := inner.count("GetPipeline"); got != 0 {
+		t.Fatalf("expected no pipeline to be read on its own, got %d", got)
+	}
+
+	pipeline, err := client.GetPipeline("app", "build", &map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	delete(pipeline, "name")
+	var p struct{ Name string }
+	if _, err := client.GetPipeline("app", "build", &p); err != nil || p.Name != "build" {
+		t.Fatalf("expected the cache not to be changed by the caller, got %v: %v", p, err)
+	}
+
+	if _, err := client.GetPipeline("app", "missing", &p); !errors.Is(err, ErrCodeNoSuchEntityException) {
+		t.Fatalf("expected a missing pipeline to be read on its own, got %v", err)
+	}
+
+	if _, err := client.WithRunAsUser("team-svc").GetPipeline("app", "build", &p); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	if got := inner.count("GetPipelines"); got != 2 {
+		t.Fatalf("expected the pipelines to be listed again for another user, got %d", got)
+	}
+}
+
+func TestCachedClientPipelineWrites(t *testing.T) {
+	savePipeline := CreatePipeLineTask{
+		"application": "app",
+		"job": []map[string]interface{}{{
+			"type":     "savePipeline",
+			"pipeline": b64.StdEncoding.EncodeToString([]byte(`{"application": "app", "name": "deploy"}`)),
+		}},
+	}
+
+	tcs := map[string]struct {
+		write   func(client Client) error
+		evicted []string
+	}{
+		"create": {
+			write:   func(client Client) error { return client.CreatePipeline(savePipeline) },
+			evicted: []string{"deploy"},
+		},
+		"create without name": {
+			write:   func(client Client) error { return client.CreatePipeline(CreatePipeLineTask{"application": "app"}) },
+			evicted: []string{"build", "deploy", "rollback"},
+		},
+		"update": {
+			write:   func(client Client) error { return client.UpdatePipeline("1", map[string]interface{}{}) },
+			evicted: []string{"build"},
+		},
+		"delete": {
+			write:   func(client Client) error { return client.DeletePipeline("app", "rollback") },
+			evicted: []string{"rollback"},
+		},
+	}
+
+	for name, tc := range tcs {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inner := newCountingClient()
+			client := NewCachedClient(inner)
+			var p struct{ Name string }
+			if _, err := client.GetPipeline("app", "build", &p); err != nil {
+				t.Fatalf("failed: %v", err)
+			}
+
+			if err := tc.write(client.WithRunAsUser("team-svc")); err != nil {
+				t.Fatalf("failed: %v", err)
+			}
+			for _, name := range []string{"build", "deploy", "rollback"} {
+				if _, err := client.GetPipeline("app", name, &p); err != nil {
+					t.Fatalf("failed: %v", err)
+				}
+			}
+			if got := inner.count("GetPipeline"); got != len(tc.evicted) {
+				t.Fatalf("expected %v to be read again, got %d reads", tc.evicted, got)
+			}
+		})
+	}
+}
+
+func TestCachedClientApplications(t *testing.T) {
+	inner := newCountingClient()
+	client := NewCachedClient(inner)
+
+	for i := 0; i < 3; i++ {
+		var app struct {
+			Attributes struct{ Email string }
+		}
+		if err := client.GetApplication("app", &app); err != nil || app.Attributes.Email != "team@example.com" {
+			t.Fatalf("expected the application, got %v: %v", app, err)
+		}
+		if err := client.GetApplication("missing", &app); !errors.Is(err, ErrCodeNoSuchEntityException) {
+			t.Fatalf("expected the application not to exist, got %v", err)
+		}
+	}
+	if got := inner.count("GetApplication"); got != 2 {
+		t.Fatalf("expected each application to be read once, got %d", got)
+	}
+
+	if err := client.CreateApplication(CreateApplicationTask{"application": "missing"}); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	if err := client.GetApplication("missing", &map[string]interface{}{}); !errors.Is(err, ErrCodeNoSuchEntityException) {
+		t.Fatalf("failed: %v", err)
+	}
+	if got := inner.count("GetApplication"); got != 3 {
+		t.Fatalf("expected the application written to be read again, got %d", got)
+	}
+}
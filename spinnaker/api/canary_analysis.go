@@ -0,0 +1,134 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/antihax/optional"
+	"github.com/mitchellh/mapstructure"
+	gate "github.com/spinnaker/spin/cmd/gateclient"
+	gateclient "github.com/spinnaker/spin/gateapi"
+)
+
+// CanaryExecutionRequest represents the Kayenta CanaryExecutionRequest API object
+type CanaryExecutionRequest map[string]interface{}
+
+// CanaryScope describes where and when the metrics of one side of a
+// canary analysis are collected
+type CanaryScope struct {
+	Scope               string
+	Location            string
+	Start               time.Time
+	End                 time.Time
+	Step                int
+	ExtendedScopeParams map[string]string
+}
+
+// CanaryExecutionOptions are the accounts and application a standalone
+// canary analysis is run with
+type CanaryExecutionOptions struct {
+	Application        string
+	MetricsAccountName string
+	StorageAccountName string
+}
+
+// NewCanaryExecutionRequest returns a Kayenta CanaryExecutionRequest comparing
+// the experiment scope against the control scope
+func NewCanaryExecutionRequest(control, experiment CanaryScope, marginal, pass float64) CanaryExecutionRequest {
+	return map[string]interface{}{
+		// Memo: canary configs built by this provider always use the
+		// "default" scope name, see newCanaryConfigMetric
+		"scopes": map[string]interface{}{
+			"default": map[string]interface{}{
+				"controlScope":    newCanaryScope(control),
+				"experimentScope": newCanaryScope(experiment),
+			},
+		},
+		"thresholds": map[string]interface{}{
+			"marginal": marginal,
+			"pass":     pass,
+		},
+	}
+}
+
+func newCanaryScope(s CanaryScope) map[string]interface{} {
+	scope := map[string]interface{}{
+		"scope": s.Scope,
+		"start": s.Start.UTC().Format(time.RFC3339),
+		"end":   s.End.UTC().Format(time.RFC3339),
+		"step":  s.Step,
+	}
+
+	if s.Location != "" {
+		scope["location"] = s.Location
+	}
+
+	if len(s.ExtendedScopeParams) > 0 {
+		scope["extendedScopeParams"] = s.ExtendedScopeParams
+	}
+
+	return scope
+}
+
+// InitiateCanary starts a canary analysis with the canary config and returns
+// the id of the canary execution
+func InitiateCanary(client *gate.GatewayClient, canaryConfigID string, opts CanaryExecutionOptions, request CanaryExecutionRequest) (string, error) {
+	reqOpts := &gateclient.V2CanaryControllerApiInitiateCanaryUsingPOSTOpts{}
+	if opts.Application != "" {
+		reqOpts.Application = optional.NewString(opts.Application)
+	}
+	if opts.MetricsAccountName != "" {
+		reqOpts.MetricsAccountName = optional.NewString(opts.MetricsAccountName)
+	}
+	if opts.StorageAccountName != "" {
+		reqOpts.StorageAccountName = optional.NewString(opts.StorageAccountName)
+	}
+
+	ref, resp, err := client.V2CanaryControllerApi.InitiateCanaryUsingPOST(client.Context, canaryConfigID, request, reqOpts)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("encountered an error initiating canary with config %s, status code: %d", canaryConfigID, resp.StatusCode)
+	}
+
+	execution, ok := ref.(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("unexpected response initiating canary with config %s: %v", canaryConfigID, ref)
+	}
+
+	id, ok := execution["canaryExecutionId"].(string)
+	if !ok || id == "" {
+		return "", fmt.Errorf("no canaryExecutionId in response initiating canary with config %s", canaryConfigID)
+	}
+
+	return id, nil
+}
+
+// GetCanaryResult gets the status and, once complete, the result of a canary execution
+func GetCanaryResult(client *gate.GatewayClient, canaryExecutionID, storageAccountName string, dest interface{}) error {
+	opts := &gateclient.V2CanaryControllerApiGetCanaryResultUsingGET1Opts{}
+	if storageAccountName != "" {
+		opts.StorageAccountName = optional.NewString(storageAccountName)
+	}
+
+	result, resp, err := client.V2CanaryControllerApi.GetCanaryResultUsingGET1(client.Context, canaryExecutionID, opts)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return ErrCodeNoSuchEntityException
+		}
+		return fmt.Errorf("encountered an error getting canary execution %s, %s", canaryExecutionID, err.Error())
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("encountered an error getting canary execution %s, status code: %d", canaryExecutionID, resp.StatusCode)
+	}
+
+	if err := mapstructure.Decode(result, dest); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -1,13 +1,15 @@
 package api
 
 import (
-	"context"
+	"encoding/json"
 	"fmt"
-	"log"
+	"math"
 	"net/http"
+	"sort"
 	"strconv"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/himanhsugusain/terraform-provider-spinnaker/spinnaker/normalize"
 	"github.com/mitchellh/mapstructure"
 	gate "github.com/spinnaker/spin/cmd/gateclient"
 	gateclient "github.com/spinnaker/spin/gateapi"
@@ -62,6 +64,22 @@ var (
 	}
 )
 
+// canaryConfigManagedRules are the keys of a Kayenta document which are either
+// set by Kayenta or handled by the name, description and applications attributes
+var canaryConfigManagedRules = normalize.MustRules(
+	"id",
+	"name",
+	"description",
+	"applications",
+	"createdTimestamp",
+	"createdTimestampIso",
+	"updatedTimestamp",
+	"updatedTimestampIso",
+)
+
+// groupWeightsTolerance absorbs floating point error when summing weights
+const groupWeightsTolerance = 1e-6
+
 type CanaryConfig map[string]interface{}
 type Metrics []map[string]interface{}
 type Metric map[string]interface{}
@@ -74,16 +92,15 @@ func NewCanaryConfig(d *schema.ResourceData) (CanaryConfig, error) {
 	cfg["description"] = d.Get("description").(string)
 
 	if v, ok := d.GetOkExists("applications"); ok {
-		input := v.([]interface{})
-		apps := make([]string, len(input))
-		for i, input := range v.([]interface{}) {
-			app := input.(string)
-			apps[i] = app
-		}
-
+		apps := convToStringArray(v.(*schema.Set).List())
+		sort.Strings(apps)
 		cfg["applications"] = apps
 	}
 
+	if v, ok := d.GetOk("config_json"); ok {
+		return newCanaryConfigFromJSON(v.(string), cfg)
+	}
+
 	classifiers := convToMapArray(d.Get("classifier").([]interface{}))
 	if len(classifiers) > 1 {
 		return nil, fmt.Errorf("no more than one classifier block")
@@ -103,6 +120,13 @@ func NewCanaryConfig(d *schema.ResourceData) (CanaryConfig, error) {
 		return nil, err
 	}
 
+	if len(classifiers) == 1 {
+		groupWeights := cfg["classifier"].(Classifier)["groupWeights"].(map[string]float64)
+		if err := ValidateCanaryConfigClassifier(groupWeights, metricGroups(metrics)); err != nil {
+			return nil, err
+		}
+	}
+
 	cfg["metrics"] = metrics
 	//cfg["metrics"] = map[string]interface{}{}
 
@@ -119,6 +143,90 @@ func NewCanaryConfig(d *schema.ResourceData) (CanaryConfig, error) {
 	return cfg, nil
 }
 
+// newCanaryConfigFromJSON builds the canary config from a Kayenta document,
+// the attributes in overrides take precedence over the document
+func newCanaryConfigFromJSON(doc string, overrides map[string]interface{}) (CanaryConfig, error) {
+	cfg := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(doc), &cfg); err != nil {
+		return nil, fmt.Errorf("could not decode config_json: %s", err)
+	}
+
+	if err := ValidateCanaryConfigDocument(cfg); err != nil {
+		return nil, err
+	}
+
+	canaryConfigManagedRules.Apply(cfg)
+
+	for k, v := range overrides {
+		cfg[k] = v
+	}
+
+	return cfg, nil
+}
+
+// ValidateCanaryConfigDocument runs the classifier checks of HCL canary
+// configs against a decoded Kayenta document
+func ValidateCanaryConfigDocument(cfg map[string]interface{}) error {
+	classifier, ok := cfg["classifier"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("config_json has no classifier")
+	}
+
+	input, ok := classifier["groupWeights"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("config_json classifier has no groupWeights")
+	}
+
+	groupWeights := map[string]float64{}
+	for k, v := range input {
+		weight, ok := v.(float64)
+		if !ok {
+			return fmt.Errorf("weight of group %q is not a number: %v", k, v)
+		}
+		groupWeights[k] = weight
+	}
+
+	groups := []string{}
+	metrics, _ := cfg["metrics"].([]interface{})
+	for _, m := range metrics {
+		m, ok := m.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("config_json metrics must be objects")
+		}
+
+		ms, _ := m["groups"].([]interface{})
+		groups = append(groups, convToStringArray(ms)...)
+	}
+
+	return ValidateCanaryConfigClassifier(groupWeights, groups)
+}
+
+// NormalizeCanaryConfigJSON removes the keys managed by Kayenta or by other
+// attributes and re-encodes the document with sorted keys
+func NormalizeCanaryConfigJSON(doc string) (string, error) {
+	cfg := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(doc), &cfg); err != nil {
+		return "", err
+	}
+
+	return EncodeCanaryConfigJSON(cfg)
+}
+
+// EncodeCanaryConfigJSON encodes a canary config without the keys managed by
+// Kayenta or by other attributes
+func EncodeCanaryConfigJSON(cfg map[string]interface{}) (string, error) {
+	return canaryConfigManagedRules.Encode(cfg)
+}
+
+func metricGroups(metrics Metrics) []string {
+	groups := []string{}
+	for _, m := range metrics {
+		groups = append(groups, m["groups"].([]string)...)
+	}
+
+	return groups
+}
+
 func newCanaryConfigMetrics(ds []map[string]interface{}) (Metrics, error) {
 	ms := []map[string]interface{}{}
 	for _, d := range ds {
@@ -273,27 +381,109 @@ func newCanaryConfigOutliers(d map[string]interface{}) map[string]interface{} {
 func newCanaryConfigClassifier(d map[string]interface{}) (Classifier, error) {
 	c := map[string]interface{}{}
 
-	input := d["group_weights"].(map[string]interface{})
-	groupWeights := map[string]int{}
-	for k, v := range input {
-		weight, err := strconv.Atoi(v.(string))
+	groupWeights, err := ParseCanaryConfigGroupWeights(d["group_weights"].(map[string]interface{}))
+	if err != nil {
+		return nil, err
+	}
+
+	c["groupWeights"] = groupWeights
+
+	if vs, ok := d["score_thresholds"].([]interface{}); ok && len(vs) > 0 && vs[0] != nil {
+		v := vs[0].(map[string]interface{})
+		thresholds, err := newCanaryConfigScoreThresholds(v)
 		if err != nil {
 			return nil, err
 		}
 
-		groupWeights[k] = weight
+		c["scoreThresholds"] = thresholds
 	}
 
-	c["groupWeights"] = groupWeights
 	return c, nil
 }
 
+func newCanaryConfigScoreThresholds(d map[string]interface{}) (map[string]interface{}, error) {
+	marginal := d["marginal"].(float64)
+	pass := d["pass"].(float64)
+	if err := ValidateCanaryConfigScoreThresholds(marginal, pass); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"marginal": marginal,
+		"pass":     pass,
+	}, nil
+}
+
+// ParseCanaryConfigGroupWeights converts the group_weights map, whose values
+// are stored as strings by Terraform, into numeric weights
+func ParseCanaryConfigGroupWeights(input map[string]interface{}) (map[string]float64, error) {
+	groupWeights := map[string]float64{}
+	for k, v := range input {
+		weight, err := strconv.ParseFloat(v.(string), 64)
+		if err != nil {
+			return nil, fmt.Errorf("weight of group %q is not a number: %s", k, v)
+		}
+
+		if weight < 0 {
+			return nil, fmt.Errorf("weight of group %q must not be negative, got %v", k, weight)
+		}
+
+		groupWeights[k] = weight
+	}
+
+	return groupWeights, nil
+}
+
+// ValidateCanaryConfigClassifier checks that every group referenced by a metric
+// has a weight, that every weighted group is referenced by a metric and that
+// the weights total 100
+func ValidateCanaryConfigClassifier(groupWeights map[string]float64, metricGroups []string) error {
+	referenced := map[string]bool{}
+	for _, group := range metricGroups {
+		referenced[group] = true
+		if _, ok := groupWeights[group]; !ok {
+			return fmt.Errorf("metric group %q has no weight in classifier group_weights", group)
+		}
+	}
+
+	var total float64
+	for group, weight := range groupWeights {
+		if !referenced[group] {
+			return fmt.Errorf("classifier group %q is not used by any metric", group)
+		}
+		total += weight
+	}
+
+	if math.Abs(total-100) > groupWeightsTolerance {
+		return fmt.Errorf("classifier group_weights must total 100, got %v", total)
+	}
+
+	return nil
+}
+
+// ValidateCanaryConfigScoreThresholds checks that both thresholds are
+// percentages and that the marginal threshold does not exceed the pass one
+func ValidateCanaryConfigScoreThresholds(marginal, pass float64) error {
+	if marginal < 0 || marginal > 100 {
+		return fmt.Errorf("marginal score threshold must be between 0 and 100, got %v", marginal)
+	}
+
+	if pass < 0 || pass > 100 {
+		return fmt.Errorf("pass score threshold must be between 0 and 100, got %v", pass)
+	}
+
+	if marginal > pass {
+		return fmt.Errorf("marginal score threshold %v must not be greater than pass score threshold %v", marginal, pass)
+	}
+
+	return nil
+}
+
 // CreateCanaryConfig creates passed canary config
 func CreateCanaryConfig(client *gate.GatewayClient, config CanaryConfig) (string, error) {
 	opts := &gateclient.V2CanaryConfigControllerApiCreateCanaryConfigUsingPOSTOpts{}
-	ref, resp, err := client.V2CanaryConfigControllerApi.CreateCanaryConfigUsingPOST(context.Background(), config, opts)
+	ref, resp, err := client.V2CanaryConfigControllerApi.CreateCanaryConfigUsingPOST(client.Context, config, opts)
 	if err != nil {
-		log.Println(fmt.Sprintf("%#v", config))
 		return "", err
 	}
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
@@ -306,8 +496,11 @@ func CreateCanaryConfig(client *gate.GatewayClient, config CanaryConfig) (string
 
 func GetCanaryConfig(client *gate.GatewayClient, id string, dest interface{}) error {
 	opts := &gateclient.V2CanaryConfigControllerApiGetCanaryConfigUsingGETOpts{}
-	conf, resp, err := client.V2CanaryConfigControllerApi.GetCanaryConfigUsingGET(context.Background(), id, opts)
+	conf, resp, err := client.V2CanaryConfigControllerApi.GetCanaryConfigUsingGET(client.Context, id, opts)
 	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return fmt.Errorf("canary config %s not found: %w", id, ErrCodeNoSuchEntityException)
+		}
 		return err
 	}
 
@@ -324,7 +517,7 @@ func GetCanaryConfig(client *gate.GatewayClient, id string, dest interface{}) er
 
 func DeleteCanaryConfig(client *gate.GatewayClient, id string) error {
 	opts := &gateclient.V2CanaryConfigControllerApiDeleteCanaryConfigUsingDELETEOpts{}
-	resp, err := client.V2CanaryConfigControllerApi.DeleteCanaryConfigUsingDELETE(context.Background(), id, opts)
+	resp, err := client.V2CanaryConfigControllerApi.DeleteCanaryConfigUsingDELETE(client.Context, id, opts)
 	if err != nil {
 		return err
 	}
@@ -338,7 +531,7 @@ func DeleteCanaryConfig(client *gate.GatewayClient, id string) error {
 
 func UpdateCanaryConfig(client *gate.GatewayClient, id string, config CanaryConfig) error {
 	opts := &gateclient.V2CanaryConfigControllerApiUpdateCanaryConfigUsingPUTOpts{}
-	_, resp, err := client.V2CanaryConfigControllerApi.UpdateCanaryConfigUsingPUT(context.Background(), config, id, opts)
+	_, resp, err := client.V2CanaryConfigControllerApi.UpdateCanaryConfigUsingPUT(client.Context, config, id, opts)
 	if err != nil {
 		return err
 	}
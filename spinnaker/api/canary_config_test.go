@@ -0,0 +1,178 @@
+package api
+
+import (
+	"testing"
+)
+
+func TestParseCanaryConfigGroupWeights(t *testing.T) {
+	tcs := map[string]struct {
+		input      map[string]interface{}
+		shouldPass bool
+	}{
+		"pass with integer weights":  {map[string]interface{}{"cpu": "60", "memory": "40"}, true},
+		"pass with decimal weights":  {map[string]interface{}{"cpu": "33.3", "memory": "66.7"}, true},
+		"fail with non numeric":      {map[string]interface{}{"cpu": "sixty"}, false},
+		"fail with negative weights": {map[string]interface{}{"cpu": "-10", "memory": "110"}, false},
+	}
+
+	for n, tc := range tcs {
+		tc := tc
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			_, err := ParseCanaryConfigGroupWeights(tc.input)
+			if err != nil && tc.shouldPass {
+				t.Fatalf("failed: %v", err)
+			}
+			if err == nil && !tc.shouldPass {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestValidateCanaryConfigClassifier(t *testing.T) {
+	tcs := map[string]struct {
+		groupWeights map[string]float64
+		metricGroups []string
+		shouldPass   bool
+	}{
+		"pass":                        {map[string]float64{"cpu": 60, "memory": 40}, []string{"cpu", "memory"}, true},
+		"pass with shared group":      {map[string]float64{"cpu": 100}, []string{"cpu", "cpu"}, true},
+		"pass with decimal weights":   {map[string]float64{"a": 33.3, "b": 33.3, "c": 33.4}, []string{"a", "b", "c"}, true},
+		"fail with missing weight":    {map[string]float64{"cpu": 100}, []string{"cpu", "memory"}, false},
+		"fail with unused group":      {map[string]float64{"cpu": 50, "memory": 50}, []string{"cpu"}, false},
+		"fail with total under 100":   {map[string]float64{"cpu": 50, "memory": 40}, []string{"cpu", "memory"}, false},
+		"fail with total over 100":    {map[string]float64{"cpu": 60, "memory": 60}, []string{"cpu", "memory"}, false},
+		"fail without metric groups":  {map[string]float64{}, []string{}, false},
+		"fail with only unused group": {map[string]float64{"cpu": 100}, []string{}, false},
+	}
+
+	for n, tc := range tcs {
+		tc := tc
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			err := ValidateCanaryConfigClassifier(tc.groupWeights, tc.metricGroups)
+			if err != nil && tc.shouldPass {
+				t.Fatalf("failed: %v", err)
+			}
+			if err == nil && !tc.shouldPass {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestValidateCanaryConfigScoreThresholds(t *testing.T) {
+	tcs := map[string]struct {
+		marginal   float64
+		pass       float64
+		shouldPass bool
+	}{
+		"pass":                      {75, 95, true},
+		"pass with equal":           {90, 90, true},
+		"fail with marginal > pass": {95, 75, false},
+		"fail with over 100":        {75, 101, false},
+		"fail with negative":        {-1, 95, false},
+	}
+
+	for n, tc := range tcs {
+		tc := tc
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			err := ValidateCanaryConfigScoreThresholds(tc.marginal, tc.pass)
+			if err != nil && tc.shouldPass {
+				t.Fatalf("failed: %v", err)
+			}
+			if err == nil && !tc.shouldPass {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestNewCanaryConfigFromJSON(t *testing.T) {
+	doc := `{
+  "id": "9753bd1b",
+  "name": "from-deck",
+  "applications": ["deck-app"],
+  "updatedTimestamp": 1600000000000,
+  "configVersion": "1",
+  "judge": {"name": "NetflixACAJudge-v1.0", "judgeConfigurations": {}},
+  "metrics": [{"name": "CPU", "groups": ["cpu"], "query": {"type": "prometheus"}, "scopeName": "default"}],
+  "classifier": {"groupWeights": {"cpu": 100}, "scoreThresholds": {"marginal": 75, "pass": 95}}
+}`
+	overrides := map[string]interface{}{
+		"name":         "from-terraform",
+		"description":  "",
+		"applications": []string{"tf-app"},
+	}
+
+	cfg, err := newCanaryConfigFromJSON(doc, overrides)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	if cfg["name"] != "from-terraform" {
+		t.Fatalf("expected name to be overridden, got %v", cfg["name"])
+	}
+
+	if _, ok := cfg["id"]; ok {
+		t.Fatal("expected id to be removed")
+	}
+
+	if _, ok := cfg["updatedTimestamp"]; ok {
+		t.Fatal("expected updatedTimestamp to be removed")
+	}
+
+	if _, ok := cfg["metrics"]; !ok {
+		t.Fatal("expected metrics to be kept")
+	}
+
+	if _, err := newCanaryConfigFromJSON(`{"metrics": [], "classifier": {"groupWeights": {"cpu": 100}}}`, overrides); err == nil {
+		t.Fatal("expected an error for a weighted group without metric")
+	}
+}
+
+func TestNormalizeCanaryConfigJSON(t *testing.T) {
+	tcs := map[string]struct {
+		old        string
+		new        string
+		equivalent bool
+	}{
+		"equivalent with different order": {
+			`{"configVersion": "1", "metrics": []}`,
+			`{"metrics": [], "configVersion": "1"}`,
+			true,
+		},
+		"equivalent with managed keys": {
+			`{"id": "a", "name": "x", "updatedTimestamp": 1, "metrics": []}`,
+			`{"metrics": []}`,
+			true,
+		},
+		"different metrics": {
+			`{"metrics": [{"name": "CPU"}]}`,
+			`{"metrics": [{"name": "Memory"}]}`,
+			false,
+		},
+	}
+
+	for n, tc := range tcs {
+		tc := tc
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			old, err := NormalizeCanaryConfigJSON(tc.old)
+			if err != nil {
+				t.Fatalf("failed: %v", err)
+			}
+
+			new, err := NormalizeCanaryConfigJSON(tc.new)
+			if err != nil {
+				t.Fatalf("failed: %v", err)
+			}
+
+			if (old == new) != tc.equivalent {
+				t.Fatalf("expected equivalent to be %v, got %s and %s", tc.equivalent, old, new)
+			}
+		})
+	}
+}
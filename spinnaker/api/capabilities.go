@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/antihax/optional"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	gate "github.com/spinnaker/spin/cmd/gateclient"
+	gateclient "github.com/spinnaker/spin/gateapi"
+)
+
+// Capability is a feature of Spinnaker whose Gate endpoints are only there
+// when it is enabled
+type Capability string
+
+const (
+	// CapabilityPipelineTemplates is the /pipelineTemplates endpoints
+	CapabilityPipelineTemplates Capability = "pipeline templates"
+	// CapabilityCanary is the /v2/canaryConfig and /v2/canaries endpoints,
+	// Gate serves them when Kayenta is enabled
+	CapabilityCanary Capability = "canary analysis"
+)
+
+// capabilityProbes are the requests telling whether a capability is enabled,
+// a 404 means it is not. They filter on names which do not exist, so that
+// they are cheap whatever the number of templates or configs.
+var capabilityProbes = map[Capability]func(client *gate.GatewayClient) (*http.Response, error){
+	CapabilityPipelineTemplates: func(client *gate.GatewayClient) (*http.Response, error) {
+		opts := &gateclient.PipelineTemplatesControllerApiListUsingGETOpts{
+			Scopes: optional.NewInterface([]string{capabilityProbeName}),
+		}
+		_, resp, err := client.PipelineTemplatesControllerApi.ListUsingGET(client.Context, opts)
+		return resp, err
+	},
+	CapabilityCanary: func(client *gate.GatewayClient) (*http.Response, error) {
+		opts := &gateclient.V2CanaryConfigControllerApiGetCanaryConfigsUsingGETOpts{
+			Application: optional.NewString(capabilityProbeName),
+		}
+		_, resp, err := client.V2CanaryConfigControllerApi.GetCanaryConfigsUsingGET(client.Context, opts)
+		return resp, err
+	},
+}
+
+// capabilityProbeName is the scope and the application the probes filter on
+const capabilityProbeName = "terraform-provider-spinnaker-probe"
+
+// capabilityHints tell how to enable a capability
+var capabilityHints = map[Capability]string{
+	CapabilityCanary: "enable Kayenta with `hal config canary enable`",
+}
+
+// Capabilities are the version of Gate and the features enabled on it
+type Capabilities struct {
+	Version string
+	// client probes the capabilities, nil when they are all known
+	client *gate.GatewayClient
+
+	mu sync.Mutex
+	// enabled are the capabilities whose probe answered, a capability which
+	// is missing is unknown, such as when its probe was forbidden
+	enabled map[Capability]bool
+	// probed are the capabilities probed already, whatever the answer
+	probed map[Capability]bool
+}
+
+// NewCapabilities returns capabilities of Gate version with the known
+// capabilities of enabled, the others are never probed
+func NewCapabilities(version string, enabled map[Capability]bool) *Capabilities {
+	return &Capabilities{Version: version, enabled: enabled}
+}
+
+// DetectCapabilities returns the capabilities of the Gate of version, as read
+// by NewGateClientVersion. Each capability is probed the first time a resource
+// requires it, so that the resources fail with a clear message rather than a
+// 404 in the middle of an apply and a provider whose resources need none makes
+// no request.
+func DetectCapabilities(client *gate.GatewayClient, version string) *Capabilities {
+	c := NewCapabilities(version, map[Capability]bool{})
+	c.client = client
+	c.probed = map[Capability]bool{}
+	return c
+}
+
+// probe asks Gate whether the capability is enabled, once
+func (c *Capabilities) probe(ctx context.Context, capability Capability) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	probe, ok := capabilityProbes[capability]
+	if c.client == nil || c.probed[capability] || !ok {
+		return
+	}
+	c.probed[capability] = true
+
+	client := WithContext(c.client, ctx)
+	resp, err := probe(client)
+	switch {
+	case resp != nil && resp.StatusCode == http.StatusNotFound:
+		c.enabled[capability] = false
+	case resp != nil && resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		c.enabled[capability] = true
+	default:
+		fields := map[string]interface{}{"capability": string(capability)}
+		if err != nil {
+			fields["error"] = err.Error()
+		}
+		tflog.SubsystemDebug(ctx, LogGate, "Could not tell whether Gate has the capability", fields)
+		return
+	}
+
+	tflog.SubsystemDebug(ctx, LogGate, "Detected a capability of Gate", map[string]interface{}{
+		"version":    c.Version,
+		"capability": string(capability),
+		"enabled":    c.enabled[capability],
+	})
+}
+
+// Require returns an error when Gate does not have the capability, which is
+// probed with ctx the first time. An unknown capability, or no capabilities
+// at all, is assumed to be enabled.
+func (c *Capabilities) Require(ctx context.Context, capability Capability) error {
+	if c == nil {
+		return nil
+	}
+	c.probe(ctx, capability)
+
+	c.mu.Lock()
+	enabled, ok := c.enabled[capability]
+	c.mu.Unlock()
+	if !ok || enabled {
+		return nil
+	}
+
+	version := c.Version
+	if version == "" {
+		version = "unknown"
+	}
+	msg := fmt.Sprintf("%s not enabled on this Gate (version %s)", capability, version)
+	if hint, ok := capabilityHints[capability]; ok {
+		msg += ", " + hint
+	}
+	return errors.New(msg)
+}
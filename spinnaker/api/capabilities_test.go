@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDetectCapabilities(t *testing.T) {
+	tcs := map[string]struct {
+		status   int
+		expected string
+	}{
+		"enabled": {
+			status: http.StatusOK,
+		},
+		"disabled": {
+			status:   http.StatusNotFound,
+			expected: "canary analysis not enabled on this Gate (version 1.28.0), enable Kayenta",
+		},
+		"forbidden": {
+			status: http.StatusForbidden,
+		},
+	}
+
+	for name, tc := range tcs {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var mu sync.Mutex
+			requests := map[string]int{}
+			gate := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				requests[r.URL.Path]++
+				mu.Unlock()
+
+				w.Header().Set("Content-Type", "application/json")
+				switch r.URL.Path {
+				case "/v2/canaryConfig":
+					w.WriteHeader(tc.status)
+					fmt.Fprint(w, `[]`)
+				case "/pipelineTemplates":
+					if r.URL.Query().Get("scopes") != capabilityProbeName {
+						w.WriteHeader(http.StatusBadRequest)
+					}
+					fmt.Fprint(w, `[]`)
+				default:
+					fmt.Fprint(w, `{"version": "1.28.0"}`)
+				}
+			}))
+			defer gate.Close()
+
+			client, version, err := NewGateClientVersion(testClientConfig(t, gate.URL))
+			if err != nil {
+				t.Fatalf("failed: %v", err)
+			}
+			capabilities := DetectCapabilities(client, version)
+
+			if capabilities.Version != "1.28.0" {
+				t.Fatalf("expected version 1.28.0, got %s", capabilities.Version)
+			}
+			if requests["/pipelineTemplates"] != 0 || requests["/v2/canaryConfig"] != 0 {
+				t.Fatalf("expected no probe before a capability is required, got %v", requests)
+			}
+			if err := capabilities.Require(context.Background(), CapabilityPipelineTemplates); err != nil {
+				t.Fatalf("failed: %v", err)
+			}
+
+			for i := 0; i < 2; i++ {
+				err = capabilities.Require(context.Background(), CapabilityCanary)
+				if tc.expected == "" && err != nil {
+					t.Fatalf("failed: %v", err)
+				}
+				if tc.expected != "" && (err == nil || !strings.Contains(err.Error(), tc.expected)) {
+					t.Fatalf("expected %q, got %v", tc.expected, err)
+				}
+			}
+
+			// The version is read once, by the client, and every capability is
+			// probed once
+			for path, expected := range map[string]int{"/version": 1, "/pipelineTemplates": 1, "/v2/canaryConfig": 1} {
+				if requests[path] != expected {
+					t.Fatalf("expected %d requests to %s, got %v", expected, path, requests)
+				}
+			}
+		})
+	}
+}
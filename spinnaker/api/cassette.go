@@ -0,0 +1,316 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// CassetteMode is whether a cassette records the interactions with Gate or
+// replays them
+type CassetteMode int
+
+const (
+	// CassetteRecord sends the requests to Gate and saves the interactions
+	CassetteRecord CassetteMode = iota + 1
+	// CassetteReplay answers the requests with the saved interactions, Gate
+	// is never reached
+	CassetteReplay
+)
+
+// Cassette is the content of a fixture file. Only the method, the path, the
+// query and the JSON body of the requests are kept, never their headers, and
+// the values of the keys which look like secrets are redacted from the
+// bodies, such as the access_token of an OAuth2 token response, so that no
+// credentials end up in the fixtures.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a request made to Gate and its response
+type Interaction struct {
+	Request  CassetteRequest  `json:"request"`
+	Response CassetteResponse `json:"response"`
+}
+
+// CassetteRequest is a recorded request, the JSON body is compared on replay
+// so that a change of the payloads, such as the base64 encoded pipeline of a
+// savePipeline job, fails the test
+type CassetteRequest struct {
+	Method string          `json:"method"`
+	URL    string          `json:"url"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// CassetteResponse is a recorded response, a body which is not JSON is kept
+// as a JSON string
+type CassetteResponse struct {
+	StatusCode  int             `json:"status_code"`
+	ContentType string          `json:"content_type,omitempty"`
+	Body        json.RawMessage `json:"body,omitempty"`
+}
+
+// LoadCassette reads the cassette of the fixture file
+func LoadCassette(path string) (*Cassette, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read cassette: %s", err)
+	}
+
+	cassette := &Cassette{}
+	if err := json.Unmarshal(content, cassette); err != nil {
+		return nil, fmt.Errorf("could not decode cassette %s: %s", path, err)
+	}
+
+	// The bodies are indented in the file, they are compared compacted
+	for i, interaction := range cassette.Interactions {
+		if len(interaction.Request.Body) > 0 {
+			cassette.Interactions[i].Request.Body = compactJSON(interaction.Request.Body)
+		}
+	}
+	return cassette, nil
+}
+
+// Save writes the cassette to the fixture file
+func (c *Cassette) Save(path string) error {
+	content, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("could not save cassette: %s", err)
+	}
+	return os.WriteFile(path, append(content, '\n'), 0o644)
+}
+
+// Recorder records the interactions with Gate to a fixture file, or replays
+// them from it. The clients built with the same Recorder share its cassette,
+// such as the ones the provider configures for each Terraform command of a
+// test.
+type Recorder struct {
+	path string
+	mode CassetteMode
+
+	mu       sync.Mutex
+	cassette *Cassette
+	// replayed are the interactions already answered on replay
+	replayed []bool
+}
+
+// NewRecorder returns a Recorder of the fixture file, which is read right
+// away on replay
+func NewRecorder(path string, mode CassetteMode) (*Recorder, error) {
+	r := &Recorder{path: path, mode: mode, cassette: &Cassette{}}
+	switch mode {
+	case CassetteRecord:
+	case CassetteReplay:
+		cassette, err := LoadCassette(path)
+		if err != nil {
+			return nil, err
+		}
+		r.cassette = cassette
+		r.replayed = make([]bool, len(cassette.Interactions))
+	default:
+		return nil, fmt.Errorf("unknown cassette mode %d", mode)
+	}
+
+	return r, nil
+}
+
+// Mode returns whether the Recorder records or replays
+func (r *Recorder) Mode() CassetteMode {
+	return r.mode
+}
+
+// cassetteTransport sends the requests through base and records them, or
+// replays them without calling base
+type cassetteTransport struct {
+	base     http.RoundTripper
+	recorder *Recorder
+}
+
+func newCassetteTransport(base http.RoundTripper, recorder *Recorder) http.RoundTripper {
+	if recorder == nil {
+		return base
+	}
+	return &cassetteTransport{base: base, recorder: recorder}
+}
+
+func (t *cassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorded, err := newCassetteRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.recorder.mode == CassetteReplay {
+		return t.recorder.replay(req, recorded)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	contentType := resp.Header.Get("Content-Type")
+	err = t.recorder.record(Interaction{
+		Request: recorded,
+		Response: CassetteResponse{
+			StatusCode:  resp.StatusCode,
+			ContentType: contentType,
+			Body:        cassetteBody(contentType, body),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// record appends the interaction and saves the cassette, so that a test
+// failing midway still leaves the interactions so far
+func (r *Recorder) record(interaction Interaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	return r.cassette.Save(r.path)
+}
+
+// replay answers with the first interaction not replayed yet of the same
+// request, the polls of a task are thereby answered in the recorded order
+func (r *Recorder) replay(req *http.Request, recorded CassetteRequest) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var mismatch *Interaction
+	for i, interaction := range r.cassette.Interactions {
+		if r.replayed[i] || interaction.Request.Method != recorded.Method || interaction.Request.URL != recorded.URL {
+			continue
+		}
+		if !bytes.Equal(interaction.Request.Body, recorded.Body) {
+			if mismatch == nil {
+				mismatch = &r.cassette.Interactions[i]
+			}
+			continue
+		}
+
+		r.replayed[i] = true
+		return newCassetteResponse(req, interaction.Response), nil
+	}
+
+	if mismatch != nil {
+		return nil, fmt.Errorf("the body of %s %s differs from cassette %s:\n  recorded: %s\n  sent:     %s",
+			recorded.Method, recorded.URL, r.path, mismatch.Request.Body, recorded.Body)
+	}
+	return nil, fmt.Errorf("no interaction left in cassette %s for %s %s", r.path, recorded.Method, recorded.URL)
+}
+
+func newCassetteRequest(req *http.Request) (CassetteRequest, error) {
+	recorded := CassetteRequest{Method: req.Method, URL: req.URL.RequestURI()}
+	if req.Body == nil || req.Body == http.NoBody {
+		return recorded, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return recorded, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	// Only JSON bodies are kept, the forms of the login carry credentials.
+	// The bodies are redacted on replay as well so that they compare equal.
+	if isJSON(req.Header.Get("Content-Type")) {
+		recorded.Body = compactJSON([]byte(redactJSON(string(body))))
+	}
+	return recorded, nil
+}
+
+func newCassetteResponse(req *http.Request, recorded CassetteResponse) *http.Response {
+	body := []byte(recorded.Body)
+	var text string
+	if !isJSON(recorded.ContentType) && json.Unmarshal(recorded.Body, &text) == nil {
+		body = []byte(text)
+	}
+
+	header := http.Header{}
+	if recorded.ContentType != "" {
+		header.Set("Content-Type", recorded.ContentType)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// cassetteBody keeps JSON bodies as is, so that the fixtures are readable
+// and diffable, and any other body as a JSON string. The secrets of both are
+// redacted, a token endpoint may answer with a form as well.
+func cassetteBody(contentType string, body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	if isJSON(contentType) && json.Valid(body) {
+		return compactJSON([]byte(redactJSON(string(body))))
+	}
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == "application/x-www-form-urlencoded" {
+		body = redactForm(body)
+	}
+
+	text, _ := json.Marshal(string(body))
+	return text
+}
+
+// redactForm redacts the values of the keys of a form which look like they
+// hold a secret
+func redactForm(body []byte) []byte {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return []byte(redacted)
+	}
+	for key := range values {
+		if secretKeyRegexp.MatchString(key) {
+			values.Set(key, redacted)
+		}
+	}
+	return []byte(values.Encode())
+}
+
+func compactJSON(body []byte) json.RawMessage {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, body); err != nil {
+		text, _ := json.Marshal(string(body))
+		return text
+	}
+	return buf.Bytes()
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestCassetteGate returns a Gate stand-in answering the tasks and the
+// applications of the task requests
+func newTestCassetteGate(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/tasks":
+			fmt.Fprint(w, `{"ref": "/tasks/01ABC"}`)
+		case strings.HasPrefix(r.URL.Path, "/tasks/"):
+			fmt.Fprint(w, `{"id": "01ABC", "status": "SUCCEEDED"}`)
+		case strings.HasPrefix(r.URL.Path, "/applications/"):
+			fmt.Fprintf(w, `{"name": "%s", "attributes": {"email": "acceptance@test.com"}}`, strings.TrimPrefix(r.URL.Path, "/applications/"))
+		default:
+			fmt.Fprint(w, `{"version": "1.0.0"}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func newTestRecorder(t *testing.T, path string, mode CassetteMode) *Recorder {
+	recorder, err := NewRecorder(path, mode)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	return recorder
+}
+
+func TestCassetteRecordReplay(t *testing.T) {
+	gate := newTestCassetteGate(t)
+	path := filepath.Join(t.TempDir(), "cassettes", "TestCassetteRecordReplay.json")
+	task := CreateApplicationTask{
+		"application": "app",
+		"job":         []interface{}{map[string]interface{}{"type": "createApplication", "application": map[string]interface{}{"name": "app"}}},
+	}
+
+	cfg := testClientConfig(t, gate.URL)
+	cfg.AccessToken = "static-token"
+	cfg.Cassette = newTestRecorder(t, path, CassetteRecord)
+	client, err := NewGateClient(cfg)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	if err := CreateApplication(client, task); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	if strings.Contains(string(content), "static-token") {
+		t.Fatalf("expected no credentials in the cassette, got %s", content)
+	}
+	if !strings.Contains(string(content), `"type": "createApplication"`) {
+		t.Fatalf("expected the payload of the task in the cassette, got %s", content)
+	}
+
+	// Gate is gone, the replay must not need it
+	gate.Close()
+	cfg.Cassette = newTestRecorder(t, path, CassetteReplay)
+	client, err = NewGateClient(cfg)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	if err := CreateApplication(client, task); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	// A changed payload fails the replay
+	cfg.Cassette = newTestRecorder(t, path, CassetteReplay)
+	client, err = NewGateClient(cfg)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	task["description"] = "changed"
+	if err := CreateApplication(client, task); err == nil || !strings.Contains(err.Error(), "differs from cassette") {
+		t.Fatalf("expected the changed payload to fail the replay, got %v", err)
+	}
+}
+
+func TestCassetteReplayExhausted(t *testing.T) {
+	gate := newTestCassetteGate(t)
+	path := filepath.Join(t.TempDir(), "TestCassetteReplayExhausted.json")
+
+	cfg := testClientConfig(t, gate.URL)
+	cfg.Cassette = newTestRecorder(t, path, CassetteRecord)
+	client, err := NewGateClient(cfg)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	if err := GetApplication(client, "app", &map[string]interface{}{}); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	// The clients of a recorder share its cassette
+	if _, err := NewGateClient(cfg); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	if len(cassette.Interactions) != 3 {
+		t.Fatalf("expected the interactions of both clients, got %v", cassette.Interactions)
+	}
+
+	cfg.Cassette = newTestRecorder(t, path, CassetteReplay)
+	client, err = NewGateClient(cfg)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	app := map[string]interface{}{}
+	if err := GetApplication(client, "app", &app); err != nil || app["name"] != "app" {
+		t.Fatalf("expected the recorded application, got %v: %v", app, err)
+	}
+	if err := GetApplication(client, "app", &app); err == nil || !strings.Contains(err.Error(), "no interaction left") {
+		t.Fatalf("expected the replay to run out of interactions, got %v", err)
+	}
+}
+
+func TestCassetteRedactsOAuth2Token(t *testing.T) {
+	gate := newTestCassetteGate(t)
+	tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token": "issued-token", "token_type": "Bearer", "expires_in": 3600}`)
+	}))
+	t.Cleanup(tokens.Close)
+	path := filepath.Join(t.TempDir(), "TestCassetteRedactsOAuth2Token.json")
+
+	cfg := testClientConfig(t, gate.URL)
+	cfg.OAuth2 = &OAuth2Config{TokenURL: tokens.URL, ClientID: "provider", ClientSecret: "client-secret"}
+	cfg.Cassette = newTestRecorder(t, path, CassetteRecord)
+	client, err := NewGateClient(cfg)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	if err := GetApplication(client, "app", &map[string]interface{}{}); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	for _, secret := range []string{"issued-token", "client-secret"} {
+		if strings.Contains(string(content), secret) {
+			t.Fatalf("expected %s to be redacted from the cassette, got %s", secret, content)
+		}
+	}
+
+	// The redacted token exchange is replayed without the token endpoint
+	tokens.Close()
+	gate.Close()
+	cfg.Cassette = newTestRecorder(t, path, CassetteReplay)
+	client, err = NewGateClient(cfg)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	if err := GetApplication(client, "app", &map[string]interface{}{}); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+}
+
+func TestCassetteBodyRedactsForm(t *testing.T) {
+	body := cassetteBody("application/x-www-form-urlencoded", []byte("access_token=issued-token&scope=read&token_type=bearer"))
+	if strings.Contains(string(body), "issued-token") || !strings.Contains(string(body), "scope=read") {
+		t.Fatalf("expected only the token to be redacted, got %s", body)
+	}
+}
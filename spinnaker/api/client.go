@@ -0,0 +1,254 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	gate "github.com/spinnaker/spin/cmd/gateclient"
+	"github.com/spinnaker/spin/config"
+	gateclient "github.com/spinnaker/spin/gateapi"
+	"github.com/spinnaker/spin/version"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultGateEndpoint is the Gate the client reaches when neither the
+// configuration nor the spin config file set one
+const DefaultGateEndpoint = "http://localhost:8084"
+
+// ClientConfig holds the settings the Gate client is built with
+type ClientConfig struct {
+	GateEndpoint     string
+	ConfigPath       string
+	DefaultHeaders   string
+	IgnoreCertErrors bool
+	IgnoreRedirects  bool
+
+	// Retry is the retry policy of requests failing with a transient error,
+	// a zero value disables retries
+	Retry RetryConfig
+	// Limit caps the rate and the concurrency of the requests to Gate
+	Limit LimitConfig
+
+	// ClientCert and ClientKey are the PEM content, or the path, of the
+	// x509 client certificate presented to Gate
+	ClientCert string
+	ClientKey  string
+	// CABundle is the PEM content, or the path, of the CA certificates Gate
+	// is verified with in addition to the system ones
+	CABundle string
+
+	// AccessToken is sent as a bearer token on every request
+	AccessToken string
+	// OAuth2 fetches and refreshes bearer tokens with the client credentials grant
+	OAuth2 *OAuth2Config
+	// Basic logs in to Gate's /login form and keeps the session cookie
+	Basic *BasicAuthConfig
+
+	// Headers are sent on every request, they take precedence over DefaultHeaders
+	Headers map[string]string
+	// HeadersFromEnv maps header names to the environment variable holding their value
+	HeadersFromEnv map[string]string
+
+	// RunAsUser is the user, usually a service account, Gate requests are
+	// made as unless a resource overrides it
+	RunAsUser string
+
+	// Cassette records the interactions with Gate to a fixture file, or
+	// replays them from it, for the tests of the provider
+	Cassette *Recorder
+
+	// Output receives the messages of the spin config authentication flows
+	Output func(string)
+
+	// Context carries the loggers the requests made while the client is
+	// built, such as the version check, are logged with
+	Context context.Context
+}
+
+// NewGateClient returns a Gate client configured like spin's gateclient.NewGateClient,
+// with the provider's own authentication applied to the HTTP client before
+// Gate is first reached.
+func NewGateClient(cfg ClientConfig) (*gate.GatewayClient, error) {
+	client, _, err := NewGateClientVersion(cfg)
+	return client, err
+}
+
+// NewGateClientVersion is NewGateClient which also returns the version of
+// Gate, as read when checking that Gate is reachable
+func NewGateClientVersion(cfg ClientConfig) (*gate.GatewayClient, string, error) {
+	spinConfig, err := LoadSpinConfig(cfg.ConfigPath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	endpoint := cfg.GateEndpoint
+	if endpoint == "" {
+		endpoint = spinConfig.Gate.Endpoint
+	}
+	if endpoint == "" {
+		endpoint = DefaultGateEndpoint
+	}
+	spinConfig.Gate.Endpoint = endpoint
+	cfg.GateEndpoint = endpoint
+
+	httpClient, err := gate.InitializeHTTPClient(spinConfig.Auth)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not initialize http client: %s", err)
+	}
+
+	if cfg.IgnoreRedirects || (spinConfig.Auth != nil && spinConfig.Auth.IgnoreRedirects) {
+		httpClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+
+	transport := httpClient.Transport.(*http.Transport)
+	if err := applyTLSConfig(transport, cfg); err != nil {
+		return nil, "", err
+	}
+
+	if cfg.IgnoreCertErrors {
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		transport.TLSClientConfig.InsecureSkipVerify = true
+	}
+
+	// Retries sit below the authentication so that every attempt carries a
+	// valid token or session, and above the limits so that every attempt is
+	// paced and a request waiting to be retried does not hold a slot. Every
+	// attempt is logged as sent, with its credentials redacted.
+	httpClient.Transport = newRetryTransport(newLimitTransport(newLogTransport(transport, cfg), cfg.Limit), cfg.Retry)
+
+	// The cassette sees what the provider sends and gets, the retries and
+	// the pacing of a recording are not replayed
+	httpClient.Transport = newCassetteTransport(httpClient.Transport, cfg.Cassette)
+
+	ctx, err := gate.ContextWithAuth(context.Background(), spinConfig.Auth)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if spinConfig.Auth != nil && spinConfig.Auth.Enabled {
+		output := cfg.Output
+		if output == nil {
+			output = func(string) {}
+		}
+
+		if _, err := gate.Authenticate(output, httpClient, endpoint, spinConfig.Auth); err != nil {
+			return nil, "", fmt.Errorf("authentication with the spin config failed: %s", err)
+		}
+	}
+
+	httpClient.Transport, err = newAuthTransport(cfg, httpClient)
+	if err != nil {
+		return nil, "", err
+	}
+	httpClient.Transport = &runAsUserTransport{base: httpClient.Transport}
+
+	headers, err := resolveHeaders(cfg)
+	if err != nil {
+		return nil, "", err
+	}
+
+	client := &gate.GatewayClient{
+		APIClient: gateclient.NewAPIClient(&gateclient.Configuration{
+			BasePath:      endpoint,
+			DefaultHeader: headers,
+			UserAgent:     fmt.Sprintf("%s/%s", version.UserAgent, version.String()),
+			HTTPClient:    httpClient,
+		}),
+		Config:  *spinConfig,
+		Context: ctx,
+	}
+	client = WithRunAsUser(client, cfg.RunAsUser)
+
+	probe := client
+	if cfg.Context != nil {
+		probe = WithContext(client, cfg.Context)
+	}
+	version, _, err := probe.VersionControllerApi.GetVersionUsingGET(probe.Context)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not reach Gate at %s, please ensure it is running: %s", endpoint, err)
+	}
+
+	return client, version.Version, nil
+}
+
+// LoadSpinConfig reads the spin CLI config, ~/.spin/config when path is
+// empty. A missing config file or home directory results in an empty config.
+func LoadSpinConfig(path string) (*config.Config, error) {
+	spinConfig := &config.Config{}
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return spinConfig, nil
+		}
+		path = filepath.Join(home, ".spin", "config")
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return spinConfig, nil
+		}
+		return nil, fmt.Errorf("could not read spin config %s: %s", path, err)
+	}
+
+	if err := yaml.UnmarshalStrict([]byte(os.ExpandEnv(string(content))), spinConfig); err != nil {
+		return nil, fmt.Errorf("could not deserialize spin config %s: %s", path, err)
+	}
+
+	return spinConfig, nil
+}
+
+func parseDefaultHeaders(defaultHeaders string) (map[string]string, error) {
+	headers := map[string]string{}
+	if defaultHeaders == "" {
+		return headers, nil
+	}
+
+	for _, element := range strings.Split(defaultHeaders, ",") {
+		header := strings.SplitN(element, "=", 2)
+		if len(header) != 2 {
+			return nil, fmt.Errorf("bad default_headers value, use key=value form: %s", element)
+		}
+		headers[strings.TrimSpace(header[0])] = strings.TrimSpace(header[1])
+	}
+
+	return headers, nil
+}
+
+// resolveHeaders merges the default headers with the headers and the headers
+// read from the environment, an unset variable is an error so that a missing
+// proxy token is not silently dropped
+func resolveHeaders(cfg ClientConfig) (map[string]string, error) {
+	headers, err := parseDefaultHeaders(cfg.DefaultHeaders)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range cfg.Headers {
+		headers[name] = value
+	}
+
+	for name, env := range cfg.HeadersFromEnv {
+		if _, ok := cfg.Headers[name]; ok {
+			return nil, fmt.Errorf("header %s is set in both headers and headers_from_env", name)
+		}
+
+		value, ok := os.LookupEnv(env)
+		if !ok || value == "" {
+			return nil, fmt.Errorf("environment variable %s of header %s is not set", env, name)
+		}
+		headers[name] = value
+	}
+
+	return headers, nil
+}
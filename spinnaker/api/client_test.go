@@ -0,0 +1,208 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// newTestGate returns a Gate stand-in which records the Authorization
+// header of every request
+func newTestGate(t *testing.T) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	authorizations := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"version": "1.0.0"}`)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, authorizations...)
+	}
+}
+
+func testClientConfig(t *testing.T, endpoint string) ClientConfig {
+	return ClientConfig{
+		GateEndpoint: endpoint,
+		ConfigPath:   filepath.Join(t.TempDir(), "config"),
+	}
+}
+
+func TestNewGateClientAccessToken(t *testing.T) {
+	gate, authorizations := newTestGate(t)
+
+	cfg := testClientConfig(t, gate.URL)
+	cfg.AccessToken = "static-token"
+	client, err := NewGateClient(cfg)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	if _, _, err := client.VersionControllerApi.GetVersionUsingGET(client.Context); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	for _, got := range authorizations() {
+		if got != "Bearer static-token" {
+			t.Fatalf("expected bearer token, got %q", got)
+		}
+	}
+}
+
+func TestNewGateClientOAuth2(t *testing.T) {
+	var mu sync.Mutex
+	issued := 0
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("could not parse token request: %v", err)
+		}
+		if got := r.PostForm.Get("grant_type"); got != "client_credentials" {
+			t.Errorf("expected client_credentials grant, got %q", got)
+		}
+		if got := r.PostForm.Get("scope"); got != "spinnaker" {
+			t.Errorf("expected spinnaker scope, got %q", got)
+		}
+
+		mu.Lock()
+		issued++
+		n := issued
+		mu.Unlock()
+
+		// Tokens expire right away so that every request refreshes it
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "Bearer", "expires_in": 1}`, n)
+	}))
+	t.Cleanup(tokenServer.Close)
+
+	gate, authorizations := newTestGate(t)
+
+	cfg := testClientConfig(t, gate.URL)
+	cfg.OAuth2 = &OAuth2Config{
+		TokenURL:     tokenServer.URL,
+		ClientID:     "terraform",
+		ClientSecret: "secret",
+		Scopes:       []string{"spinnaker"},
+	}
+	client, err := NewGateClient(cfg)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	if _, _, err := client.VersionControllerApi.GetVersionUsingGET(client.Context); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	got := authorizations()
+	if len(got) != 2 {
+		t.Fatalf("expected 2 requests to Gate, got %d", len(got))
+	}
+
+	if got[0] == got[1] {
+		t.Fatalf("expected the expired token to be refreshed, got %q twice", got[0])
+	}
+
+	for _, v := range got {
+		if len(v) < len("Bearer token-") || v[:len("Bearer token-")] != "Bearer token-" {
+			t.Fatalf("expected an issued bearer token, got %q", v)
+		}
+	}
+}
+
+func TestNewGateClientAuthMutuallyExclusive(t *testing.T) {
+	gate, _ := newTestGate(t)
+
+	tcs := map[string]ClientConfig{
+		"fail with access token and oauth2": {AccessToken: "static-token", OAuth2: &OAuth2Config{TokenURL: gate.URL}},
+		"fail with access token and basic":  {AccessToken: "static-token", Basic: &BasicAuthConfig{Username: "admin", Password: "secret"}},
+		"fail with oauth2 and basic":        {OAuth2: &OAuth2Config{TokenURL: gate.URL}, Basic: &BasicAuthConfig{Username: "admin", Password: "secret"}},
+	}
+
+	for n, tc := range tcs {
+		tc := tc
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			cfg := testClientConfig(t, gate.URL)
+			cfg.AccessToken = tc.AccessToken
+			cfg.OAuth2 = tc.OAuth2
+			cfg.Basic = tc.Basic
+			if _, err := NewGateClient(cfg); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestParseDefaultHeaders(t *testing.T) {
+	headers, err := parseDefaultHeaders("a=1, b = 2=3")
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	if headers["a"] != "1" || headers["b"] != "2=3" {
+		t.Fatalf("unexpected headers: %v", headers)
+	}
+
+	if _, err := parseDefaultHeaders("a"); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestResolveHeaders(t *testing.T) {
+	t.Setenv("TEST_PROXY_TOKEN", "from-env")
+
+	tcs := map[string]struct {
+		cfg        ClientConfig
+		expected   map[string]string
+		shouldPass bool
+	}{
+		"pass with headers over default headers": {
+			ClientConfig{DefaultHeaders: "a=1,b=2", Headers: map[string]string{"b": "3"}},
+			map[string]string{"a": "1", "b": "3"},
+			true,
+		},
+		"pass with headers from env": {
+			ClientConfig{HeadersFromEnv: map[string]string{"Proxy-Authorization": "TEST_PROXY_TOKEN"}},
+			map[string]string{"Proxy-Authorization": "from-env"},
+			true,
+		},
+		"fail with unset env": {
+			ClientConfig{HeadersFromEnv: map[string]string{"Proxy-Authorization": "TEST_UNSET_PROXY_TOKEN"}},
+			nil,
+			false,
+		},
+		"fail with header in both": {
+			ClientConfig{Headers: map[string]string{"a": "1"}, HeadersFromEnv: map[string]string{"a": "TEST_PROXY_TOKEN"}},
+			nil,
+			false,
+		},
+	}
+
+	for n, tc := range tcs {
+		tc := tc
+		t.Run(n, func(t *testing.T) {
+			headers, err := resolveHeaders(tc.cfg)
+			if err != nil && tc.shouldPass {
+				t.Fatalf("failed: %v", err)
+			}
+			if err == nil && !tc.shouldPass {
+				t.Fatal("expected an error")
+			}
+
+			for k, v := range tc.expected {
+				if headers[k] != v {
+					t.Fatalf("expected %s to be %q, got %q", k, v, headers[k])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"context"
+
+	gate "github.com/spinnaker/spin/cmd/gateclient"
+)
+
+// Client is the Gate operations the provider uses. GateClient implements it
+// against Gate, and fakegate in memory for the unit tests of the resources.
+type Client interface {
+	// WithRunAsUser returns a client making its requests as user
+	WithRunAsUser(user string) Client
+	// WithContext returns a client whose requests and task polling stop with ctx
+	WithContext(ctx context.Context) Client
+
+	GetApplication(appName string, dest interface{}) error
+	CreateApplication(createAppTask CreateApplicationTask) error
+	DeleteApplication(appName string) error
+
+	CreatePipeline(createPipeLineTask CreatePipeLineTask) error
+	GetPipeline(applicationName, pipelineName string, dest interface{}) (map[string]interface{}, error)
+	GetPipelineByID(pipelineID string, dest interface{}) (map[string]interface{}, error)
+	GetPipelines(applicationName string) ([]map[string]interface{}, error)
+	UpdatePipeline(pipelineID string, pipeline interface{}) error
+	DeletePipeline(applicationName, pipelineName string) error
+
+	CreatePipelineTemplate(template interface{}) error
+	GetPipelineTemplate(templateID string, dest interface{}) error
+	UpdatePipelineTemplate(templateID string, template interface{}) error
+	DeletePipelineTemplate(templateID string) error
+
+	GetProject(projectName string, dest interface{}) error
+	GetProjects() ([]map[string]interface{}, error)
+	CreateProject(upsertProjectTask UpsertApplicationTask) error
+	DeleteProject(id string, projectName string) error
+
+	CreateCanaryConfig(config CanaryConfig) (string, error)
+	GetCanaryConfig(id string, dest interface{}) error
+	UpdateCanaryConfig(id string, config CanaryConfig) error
+	DeleteCanaryConfig(id string) error
+
+	InitiateCanary(canaryConfigID string, opts CanaryExecutionOptions, request CanaryExecutionRequest) (string, error)
+	GetCanaryResult(canaryExecutionID, storageAccountName string, dest interface{}) error
+}
+
+// GateClient is the Client talking to Gate through spin's gateclient
+type GateClient struct {
+	client *gate.GatewayClient
+}
+
+var _ Client = &GateClient{}
+
+// NewClient returns the Client of the Gate client
+func NewClient(client *gate.GatewayClient) *GateClient {
+	return &GateClient{client: client}
+}
+
+// GatewayClient returns the underlying spin Gate client
+func (c *GateClient) GatewayClient() *gate.GatewayClient {
+	return c.client
+}
+
+func (c *GateClient) WithRunAsUser(user string) Client {
+	return NewClient(WithRunAsUser(c.client, user))
+}
+
+func (c *GateClient) WithContext(ctx context.Context) Client {
+	return NewClient(WithContext(c.client, ctx))
+}
+
+func (c *GateClient) GetApplication(appName string, dest interface{}) error {
+	return GetApplication(c.client, appName, dest)
+}
+
+func (c *GateClient) CreateApplication(createAppTask CreateApplicationTask) error {
+	return CreateApplication(c.client, createAppTask)
+}
+
+func (c *GateClient) DeleteApplication(appName string) error {
+	return DeleteApplication(c.client, appName)
+}
+
+func (c *GateClient) CreatePipeline(createPipeLineTask CreatePipeLineTask) error {
+	return CretePipeLineWithTask(c.client, createPipeLineTask)
+}
+
+func (c *GateClient) GetPipeline(applicationName, pipelineName string, dest interface{}) (map[string]interface{}, error) {
+	return GetPipeline(c.client, applicationName, pipelineName, dest)
+}
+
+func (c *GateClient) GetPipelineByID(pipelineID string, dest interface{}) (map[string]interface{}, error) {
+	return GetPipelineByID(c.client, pipelineID, dest)
+}
+
+func (c *GateClient) GetPipelines(applicationName string) ([]map[string]interface{}, error) {
+	return GetPipelines(c.client, applicationName)
+}
+
+func (c *GateClient) UpdatePipeline(pipelineID string, pipeline interface{}) error {
+	return UpdatePipeline(c.client, pipelineID, pipeline)
+}
+
+func (c *GateClient) DeletePipeline(applicationName, pipelineName string) error {
+	return DeletePipeline(c.client, applicationName, pipelineName)
+}
+
+func (c *GateClient) CreatePipelineTemplate(template interface{}) error {
+	return CreatePipelineTemplate(c.client, template)
+}
+
+func (c *GateClient) GetPipelineTemplate(templateID string, dest interface{}) error {
+	return GetPipelineTemplate(c.client, templateID, dest)
+}
+
+func (c *GateClient) UpdatePipelineTemplate(templateID string, template interface{}) error {
+	return UpdatePipelineTemplate(c.client, templateID, template)
+}
+
+func (c *GateClient) DeletePipelineTemplate(templateID string) error {
+	return DeletePipelineTemplate(c.client, templateID)
+}
+
+func (c *GateClient) GetProject(projectName string, dest interface{}) error {
+	return GetProject(c.client, projectName, dest)
+}
+
+func (c *GateClient) GetProjects() ([]map[string]interface{}, error) {
+	return GetProjects(c.client)
+}
+
+func (c *GateClient) CreateProject(upsertProjectTask UpsertApplicationTask) error {
+	return CreateProject(c.client, upsertProjectTask)
+}
+
+func (c *GateClient) DeleteProject(id string, projectName string) error {
+	return DeleteProject(c.client, id, projectName)
+}
+
+func (c *GateClient) CreateCanaryConfig(config CanaryConfig) (string, error) {
+	return CreateCanaryConfig(c.client, config)
+}
+
+func (c *GateClient) GetCanaryConfig(id string, dest interface{}) error {
+	return GetCanaryConfig(c.client, id, dest)
+}
+
+func (c *GateClient) UpdateCanaryConfig(id string, config CanaryConfig) error {
+	return UpdateCanaryConfig(c.client, id, config)
+}
+
+func (c *GateClient) DeleteCanaryConfig(id string) error {
+	return DeleteCanaryConfig(c.client, id)
+}
+
+func (c *GateClient) InitiateCanary(canaryConfigID string, opts CanaryExecutionOptions, request CanaryExecutionRequest) (string, error) {
+	return InitiateCanary(c.client, canaryConfigID, opts, request)
+}
+
+func (c *GateClient) GetCanaryResult(canaryExecutionID, storageAccountName string, dest interface{}) error {
+	return GetCanaryResult(c.client, canaryExecutionID, storageAccountName, dest)
+}
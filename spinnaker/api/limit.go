@@ -0,0 +1,106 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// LimitConfig caps the load the provider puts on Gate, a zero value disables
+// the corresponding limit
+type LimitConfig struct {
+	// RequestsPerSecond is the maximum rate requests are sent at
+	RequestsPerSecond float64
+	// ConcurrentRequests is the maximum number of requests in flight
+	ConcurrentRequests int
+}
+
+// limitTransport paces the requests of every resource sharing the client and
+// caps how many are in flight, a request holds its slot until its response
+// body is closed
+type limitTransport struct {
+	base  http.RoundTripper
+	slots chan struct{}
+
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newLimitTransport(base http.RoundTripper, config LimitConfig) http.RoundTripper {
+	if config.RequestsPerSecond <= 0 && config.ConcurrentRequests <= 0 {
+		return base
+	}
+
+	t := &limitTransport{base: base}
+	if config.RequestsPerSecond > 0 {
+		t.interval = time.Duration(float64(time.Second) / config.RequestsPerSecond)
+	}
+	if config.ConcurrentRequests > 0 {
+		t.slots = make(chan struct{}, config.ConcurrentRequests)
+	}
+
+	return t
+}
+
+func (t *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.slots != nil {
+		select {
+		case t.slots <- struct{}{}:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+
+	if err := sleepRequest(req, t.reserve()); err != nil {
+		t.release()
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		t.release()
+		return nil, err
+	}
+
+	resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: t.release}
+	return resp, nil
+}
+
+// reserve returns how long to wait before the request can be sent
+func (t *limitTransport) reserve() time.Duration {
+	if t.interval == 0 {
+		return 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if t.next.Before(now) {
+		t.next = now
+	}
+	wait := t.next.Sub(now)
+	t.next = t.next.Add(t.interval)
+
+	return wait
+}
+
+func (t *limitTransport) release() {
+	if t.slots != nil {
+		<-t.slots
+	}
+}
+
+type releaseOnClose struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releaseOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestBusyGate returns a Gate stand-in which takes delay to answer, and
+// reports the highest number of requests it served at once
+func newTestBusyGate(t *testing.T, delay time.Duration) (*httptest.Server, func() int) {
+	var mu sync.Mutex
+	inFlight, peak := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > peak {
+			peak = inFlight
+		}
+		mu.Unlock()
+
+		time.Sleep(delay)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"version": "1.0.0"}`)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return peak
+	}
+}
+
+func runConcurrentVersionRequests(t *testing.T, cfg ClientConfig, n int) {
+	client, err := NewGateClient(cfg)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := client.VersionControllerApi.GetVersionUsingGET(client.Context); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatalf("failed: %v", err)
+	}
+}
+
+func TestLimitTransportConcurrentRequests(t *testing.T) {
+	gate, peak := newTestBusyGate(t, 20*time.Millisecond)
+
+	cfg := testClientConfig(t, gate.URL)
+	cfg.Limit = LimitConfig{ConcurrentRequests: 2}
+	runConcurrentVersionRequests(t, cfg, 10)
+
+	if got := peak(); got > 2 {
+		t.Fatalf("expected at most 2 requests in flight, got %d", got)
+	}
+}
+
+func TestLimitTransportRequestsPerSecond(t *testing.T) {
+	gate, _ := newTestBusyGate(t, 0)
+
+	cfg := testClientConfig(t, gate.URL)
+	cfg.Limit = LimitConfig{RequestsPerSecond: 50}
+
+	start := time.Now()
+	// 1 request to reach Gate when the client is built, and 10 more
+	runConcurrentVersionRequests(t, cfg, 10)
+
+	// The 11th request is sent 10 intervals of 20ms after the first
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Fatalf("expected requests to be paced at 50 per second, 11 requests took %s", elapsed)
+	}
+}
+
+func TestNewLimitTransportUnlimited(t *testing.T) {
+	base := http.DefaultTransport
+	if newLimitTransport(base, LimitConfig{}) != base {
+		t.Fatal("expected the base transport without limits")
+	}
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// The subsystems of the provider logs, the level of each one is set with
+// TF_LOG_PROVIDER_SPINNAKER_<SUBSYSTEM>, such as TF_LOG_PROVIDER_SPINNAKER_GATE=TRACE
+const (
+	// LogGate logs the HTTP requests to Gate and their responses
+	LogGate = "gate"
+	// LogOrca logs the tasks submitted to Orca and their polling
+	LogOrca = "orca"
+	// LogFront50 logs the applications, projects, pipelines and pipeline
+	// templates read from and written to Front50
+	LogFront50 = "front50"
+
+	logLevelEnv = "TF_LOG_PROVIDER_SPINNAKER"
+	redacted    = "***"
+)
+
+var (
+	logSubsystems = []string{LogGate, LogOrca, LogFront50}
+
+	// sensitiveLogKeys are the log fields whose value is never logged
+	sensitiveLogKeys = []string{"password", "client_secret", "access_token", "refresh_token", "token"}
+	// credentialsRegexp matches the credentials of an Authorization header
+	credentialsRegexp = regexp.MustCompile(`(?i)\b(bearer|basic)\s+[a-z0-9._~+/=-]+`)
+	// jsonSecretRegexp matches the string values of the JSON keys which look
+	// like they hold a secret
+	jsonSecretRegexp = regexp.MustCompile(`(?i)("[^"]*(?:password|secret|token|credential)[^"]*"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+	// secretKeyRegexp matches the keys of a form which look like they hold a
+	// secret, the same as jsonSecretRegexp
+	secretKeyRegexp = regexp.MustCompile(`(?i)password|secret|token|credential`)
+	// sensitiveHeaderWords are the words of the names of the headers which
+	// are never logged, on top of the ones of the provider configuration
+	sensitiveHeaderWords = []string{"authorization", "cookie", "token", "secret", "password", "key", "session"}
+)
+
+// ContextWithLogging returns ctx with the loggers of the subsystems, which
+// mask the credentials. Outside of Terraform, such as in the unit tests,
+// there is no provider logger and nothing is logged.
+func ContextWithLogging(ctx context.Context) context.Context {
+	for _, subsystem := range logSubsystems {
+		ctx = tflog.NewSubsystem(ctx, subsystem, tflog.WithLevelFromEnv(logLevelEnv, subsystem))
+		ctx = tflog.SubsystemMaskFieldValuesWithFieldKeys(ctx, subsystem, sensitiveLogKeys...)
+		ctx = tflog.SubsystemMaskAllFieldValuesRegexes(ctx, subsystem, credentialsRegexp)
+		ctx = tflog.SubsystemMaskMessageRegexes(ctx, subsystem, credentialsRegexp)
+	}
+	return ctx
+}
+
+// logTransport logs the requests and the responses at TRACE on the gate
+// subsystem of the request context. The values of the sensitive headers and
+// of the secrets of the JSON bodies are redacted, and so are the non JSON
+// bodies, such as the form of the login.
+type logTransport struct {
+	base http.RoundTripper
+	// headers are the lower cased names of the headers of the provider
+	// configuration, which often carry the token of a proxy
+	headers map[string]bool
+}
+
+func newLogTransport(base http.RoundTripper, cfg ClientConfig) *logTransport {
+	t := &logTransport{base: base, headers: map[string]bool{}}
+
+	defaultHeaders, _ := parseDefaultHeaders(cfg.DefaultHeaders)
+	for _, headers := range []map[string]string{defaultHeaders, cfg.Headers, cfg.HeadersFromEnv} {
+		for name := range headers {
+			t.headers[strings.ToLower(name)] = true
+		}
+	}
+	return t
+}
+
+func (t *logTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	body, err := logBody(&req.Body, req.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+	tflog.SubsystemTrace(ctx, LogGate, "Sending request to Gate", map[string]interface{}{
+		"method":  req.Method,
+		"url":     req.URL.String(),
+		"headers": t.redactHeaders(req.Header),
+		"body":    body,
+	})
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		tflog.SubsystemDebug(ctx, LogGate, "Request to Gate failed", map[string]interface{}{
+			"method":   req.Method,
+			"url":      req.URL.String(),
+			"duration": time.Since(start).String(),
+			"error":    err.Error(),
+		})
+		return nil, err
+	}
+
+	body, err = logBody(&resp.Body, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+	tflog.SubsystemTrace(ctx, LogGate, "Received response from Gate", map[string]interface{}{
+		"method":      req.Method,
+		"url":         req.URL.String(),
+		"status_code": resp.StatusCode,
+		"duration":    time.Since(start).String(),
+		"headers":     t.redactHeaders(resp.Header),
+		"body":        body,
+	})
+
+	return resp, nil
+}
+
+// redactHeaders returns the headers to log, with the values of the
+// sensitive ones redacted
+func (t *logTransport) redactHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string, len(header))
+	for name, values := range header {
+		if t.isSensitiveHeader(name) {
+			headers[name] = redacted
+			continue
+		}
+		headers[name] = strings.Join(values, ", ")
+	}
+	return headers
+}
+
+func (t *logTransport) isSensitiveHeader(name string) bool {
+	name = strings.ToLower(name)
+	if t.headers[name] {
+		return true
+	}
+	for _, word := range sensitiveHeaderWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// logBody reads the body to log and puts it back, the values of the JSON
+// keys which look like secrets are redacted and a body which is not JSON is
+// not logged
+func logBody(body *io.ReadCloser, contentType string) (string, error) {
+	if *body == nil || *body == http.NoBody {
+		return "", nil
+	}
+
+	content, err := io.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return "", err
+	}
+	*body = io.NopCloser(bytes.NewReader(content))
+
+	if len(content) == 0 {
+		return "", nil
+	}
+	if !isJSON(contentType) {
+		return redacted, nil
+	}
+	return redactJSON(string(content)), nil
+}
+
+func redactJSON(content string) string {
+	return jsonSecretRegexp.ReplaceAllString(content, `${1}"`+redacted+`"`)
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-log/tflogtest"
+)
+
+func TestLogRedaction(t *testing.T) {
+	gate := newTestCassetteGate(t)
+	t.Setenv("PROXY_TOKEN", "proxy-secret")
+
+	var output bytes.Buffer
+	ctx := tflogtest.RootLogger(context.Background(), &output)
+
+	cfg := testClientConfig(t, gate.URL)
+	cfg.AccessToken = "static-token"
+	cfg.HeadersFromEnv = map[string]string{"X-Proxy": "PROXY_TOKEN"}
+	cfg.Context = ctx
+	client, err := NewGateClient(cfg)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	err = CreateApplication(WithContext(client, ctx), CreateApplicationTask{
+		"application": "app",
+		"job": []interface{}{map[string]interface{}{
+			"type":        "createApplication",
+			"application": map[string]interface{}{"name": "app", "dbPassword": "app-secret"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	logs := output.String()
+	entries, err := tflogtest.MultilineJSONDecode(&output)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	modules := map[string]int{}
+	for _, entry := range entries {
+		module, _ := entry["@module"].(string)
+		modules[module]++
+	}
+	tcs := map[string]int{
+		"provider.gate": 6, // the version check, the task and its poll
+		"provider.orca": 3,
+	}
+	for module, min := range tcs {
+		if modules[module] < min {
+			t.Fatalf("expected at least %d %s logs, got %v", min, module, modules)
+		}
+	}
+
+	for _, secret := range []string{"static-token", "proxy-secret", "app-secret"} {
+		if strings.Contains(logs, secret) {
+			t.Fatalf("expected %s to be redacted, got %s", secret, logs)
+		}
+	}
+	if !strings.Contains(logs, `\"type\":\"createApplication\"`) {
+		t.Fatalf("expected the body of the task in the logs, got %s", logs)
+	}
+}
+
+func TestLogTransportSensitiveHeaders(t *testing.T) {
+	transport := newLogTransport(nil, ClientConfig{DefaultHeaders: "X-Team=infra", Headers: map[string]string{"X-Proxy": "secret"}})
+
+	tcs := map[string]bool{
+		"Authorization":    true,
+		"Cookie":           true,
+		"Set-Cookie":       true,
+		"X-Api-Key":        true,
+		"X-Vault-Token":    true,
+		"x-proxy":          true,
+		"X-Team":           true,
+		"Content-Type":     false,
+		"X-Spinnaker-User": false,
+	}
+
+	for name, tc := range tcs {
+		if got := transport.isSensitiveHeader(name); got != tc {
+			t.Fatalf("failed: sensitive header %s is %v, expected %v", name, got, tc)
+		}
+	}
+}
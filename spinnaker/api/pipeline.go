@@ -2,25 +2,34 @@ package api
 
 import (
 	b64 "encoding/base64"
+	"encoding/json"
 	"fmt"
 	"net/http"
 
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/antihax/optional"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"github.com/mitchellh/mapstructure"
 	gate "github.com/spinnaker/spin/cmd/gateclient"
-	orca_tasks "github.com/spinnaker/spin/cmd/orca-tasks"
+	gateclient "github.com/spinnaker/spin/gateapi"
 )
 
 type CreatePipeLineTask map[string]interface{}
 
-func NewSavePipelineTask(d *schema.ResourceData) (CreatePipeLineTask, error) {
+// NewSavePipelineTask returns the savePipeline task saving the pipeline
+// document, Front50 saves it under the application and name of the document
+func NewSavePipelineTask(pipeline map[string]interface{}) (CreatePipeLineTask, error) {
+	pipelineJSON, err := json.Marshal(pipeline)
+	if err != nil {
+		return nil, err
+	}
+
 	pipeLineTask := make(map[string]interface{})
-	pipeLineTask["application"] = d.Get("application").(string)
-	pipeLineTask["description"] = fmt.Sprintf("Save Pipeline %s", d.Get("name").(string))
+	pipeLineTask["application"] = pipeline["application"]
+	pipeLineTask["description"] = fmt.Sprintf("Save Pipeline %v", pipeline["name"])
 	pipeLineTask["job"] = []map[string]interface{}{
 		{
 			"type":     "savePipeline",
-			"pipeline": b64.StdEncoding.EncodeToString([]byte(d.Get("pipeline").(string))),
+			"pipeline": b64.StdEncoding.EncodeToString(pipelineJSON),
 		},
 	}
 	return pipeLineTask, nil
@@ -32,7 +41,7 @@ func CretePipeLineWithTask(client *gate.GatewayClient, createPipeLineTask Create
 	if err != nil {
 		return err
 	}
-	return orca_tasks.WaitForSuccessfulTask(client, ref)
+	return WaitForSuccessfulTask(client, ref)
 }
 
 func GetPipeline(client *gate.GatewayClient, applicationName, pipelineName string, dest interface{}) (map[string]interface{}, error) {
@@ -62,10 +71,76 @@ func GetPipeline(client *gate.GatewayClient, applicationName, pipelineName strin
 	if err := mapstructure.Decode(jsonMap, dest); err != nil {
 		return jsonMap, err
 	}
+	tflog.SubsystemTrace(client.Context, LogFront50, "Read pipeline", map[string]interface{}{"application": applicationName, "pipeline": pipelineName})
 
 	return jsonMap, nil
 }
 
+// GetPipelineByID returns the pipeline of the id, wherever it was renamed to.
+// Front50 tells its application and name in the latest revision of its
+// history, which is kept for deleted pipelines as well, the pipeline is then
+// read by them and must have the id still.
+func GetPipelineByID(client *gate.GatewayClient, pipelineID string, dest interface{}) (map[string]interface{}, error) {
+	opts := &gateclient.PipelineConfigControllerApiGetPipelineConfigHistoryUsingGETOpts{Limit: optional.NewInt32(1)}
+	history, resp, err := client.PipelineConfigControllerApi.GetPipelineConfigHistoryUsingGET(client.Context, pipelineID, opts)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil, ErrCodeNoSuchEntityException
+		}
+		return nil, fmt.Errorf("encountered an error getting the history of pipeline %s, %s", pipelineID, err.Error())
+	}
+
+	if len(history) == 0 {
+		return nil, ErrCodeNoSuchEntityException
+	}
+	var latest pipelineIdentity
+	if err := mapstructure.Decode(history[0], &latest); err != nil {
+		return nil, err
+	}
+
+	jsonMap, err := GetPipeline(client, latest.Application, latest.Name, &pipelineIdentity{})
+	if err != nil {
+		return nil, err
+	}
+	if jsonMap["id"] != pipelineID {
+		// Deleted, another pipeline has its name now
+		return nil, ErrCodeNoSuchEntityException
+	}
+	return jsonMap, mapstructure.Decode(jsonMap, dest)
+}
+
+// pipelineIdentity is where a pipeline is in Front50
+type pipelineIdentity struct {
+	Application string `mapstructure:"application"`
+	Name        string `mapstructure:"name"`
+}
+
+// GetPipelines returns the pipeline configs of the application, in a single
+// request to Front50
+func GetPipelines(client *gate.GatewayClient, applicationName string) ([]map[string]interface{}, error) {
+	list, resp, err := client.ApplicationControllerApi.GetPipelineConfigsForApplicationUsingGET(client.Context, applicationName)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil, ErrCodeNoSuchEntityException
+		}
+		return nil, fmt.Errorf("encountered an error getting the pipelines of application %s, %s", applicationName, err.Error())
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("encountered an error getting the pipelines of application %s, status code: %d", applicationName, resp.StatusCode)
+	}
+
+	pipelines := make([]map[string]interface{}, 0, len(list))
+	for _, v := range list {
+		if pipeline, ok := v.(map[string]interface{}); ok {
+			pipelines = append(pipelines, pipeline)
+		}
+	}
+	tflog.SubsystemTrace(client.Context, LogFront50, "Read the pipelines of the application", map[string]interface{}{"application": applicationName, "pipelines": len(pipelines)})
+
+	return pipelines, nil
+}
+
 func UpdatePipeline(client *gate.GatewayClient, pipelineID string, pipeline interface{}) error {
 	_, resp, err := client.PipelineControllerApi.UpdatePipelineUsingPUT(client.Context, pipelineID, pipeline)
 
@@ -76,6 +151,7 @@ func UpdatePipeline(client *gate.GatewayClient, pipelineID string, pipeline inte
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("encountered an error saving pipeline, status code: %d", resp.StatusCode)
 	}
+	tflog.SubsystemDebug(client.Context, LogFront50, "Saved pipeline", map[string]interface{}{"pipeline_id": pipelineID})
 
 	return nil
 }
@@ -90,6 +166,7 @@ func DeletePipeline(client *gate.GatewayClient, applicationName, pipelineName st
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("encountered an error deleting pipeline, status code: %d", resp.StatusCode)
 	}
+	tflog.SubsystemDebug(client.Context, LogFront50, "Deleted pipeline", map[string]interface{}{"application": applicationName, "pipeline": pipelineName})
 
 	return nil
 }
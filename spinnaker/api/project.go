@@ -2,13 +2,12 @@ package api
 
 import (
 	"fmt"
-	"log"
 	"net/http"
 
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/mitchellh/mapstructure"
 	gate "github.com/spinnaker/spin/cmd/gateclient"
-	orca_tasks "github.com/spinnaker/spin/cmd/orca-tasks"
 )
 
 var defaultProjectConfig = map[string]interface{}{
@@ -44,7 +43,6 @@ func NewUpsertApplicationTask(d *schema.ResourceData) (UpsertApplicationTask, er
 			for k, configInput := range configInputs {
 				switch k {
 				case "applications":
-					log.Println(configInput)
 					applications := configInput.([]interface{})
 					if !ok {
 						return nil, fmt.Errorf("can't convert applications to list of string, got:%T", configInput)
@@ -95,23 +93,35 @@ func NewUpsertApplicationTask(d *schema.ResourceData) (UpsertApplicationTask, er
 	return upsertProjectTask, nil
 }
 
+// NewUpdateProjectTask returns the upsertProject task updating the project of
+// Front50 id with the resource data, Front50 creates a project without id
+func NewUpdateProjectTask(d *schema.ResourceData, id string) (UpsertApplicationTask, error) {
+	task, err := NewUpsertApplicationTask(d)
+	if err != nil {
+		return nil, err
+	}
+
+	project := task["job"].([]interface{})[0].(map[string]interface{})["project"].(map[string]interface{})
+	project["id"] = id
+	task["description"] = fmt.Sprintf("Update project: %s", project["name"])
+	return task, nil
+}
+
 // GetApplication gets an application from Spinnaker Gate
 func GetProject(client *gate.GatewayClient, projectName string, dest interface{}) error {
 	project, resp, err := client.ProjectControllerApi.GetUsingGET1(client.Context, projectName)
 	if resp != nil {
 		if resp.StatusCode == http.StatusNotFound {
-			return fmt.Errorf("Project '%s' not found", projectName)
+			return fmt.Errorf("Project '%s' not found: %w", projectName, ErrCodeNoSuchEntityException)
 		} else if resp.StatusCode != http.StatusOK {
 			return fmt.Errorf("Encountered an error getting application, status code: %d", resp.StatusCode)
 		}
 	}
 
 	if err != nil {
-		log.Println("project", project)
-		log.Println("resp", resp)
-		log.Println("err", err)
 		return err
 	}
+	tflog.SubsystemTrace(client.Context, LogFront50, "Read project", map[string]interface{}{"project": projectName})
 
 	if err := mapstructure.Decode(project, dest); err != nil {
 		return err
@@ -120,13 +130,74 @@ func GetProject(client *gate.GatewayClient, projectName string, dest interface{}
 	return nil
 }
 
+// GetProjects returns all the projects
+func GetProjects(client *gate.GatewayClient) ([]map[string]interface{}, error) {
+	list, resp, err := client.ProjectControllerApi.AllUsingGET3(client.Context)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Encountered an error getting projects, status code: %d", resp.StatusCode)
+	}
+
+	projects := make([]map[string]interface{}, 0, len(list))
+	for _, v := range list {
+		if project, ok := v.(map[string]interface{}); ok {
+			projects = append(projects, project)
+		}
+	}
+	tflog.SubsystemTrace(client.Context, LogFront50, "Read projects", map[string]interface{}{"projects": len(projects)})
+
+	return projects, nil
+}
+
+// NewMovePipelineProjectTask returns the upsertProject task pointing the
+// pipeline configs of the project at the pipeline to its new application, which
+// is added to the applications of the project when it is not one of them, and
+// false when the project has none
+func NewMovePipelineProjectTask(project map[string]interface{}, pipelineID, application string) (UpsertApplicationTask, bool) {
+	config, _ := project["config"].(map[string]interface{})
+	pipelineConfigs, _ := config["pipelineConfigs"].([]interface{})
+
+	moved := false
+	for _, v := range pipelineConfigs {
+		pipelineConfig, ok := v.(map[string]interface{})
+		if !ok || pipelineConfig["pipelineConfigId"] != pipelineID || pipelineConfig["application"] == application {
+			continue
+		}
+		pipelineConfig["application"] = application
+		moved = true
+	}
+	if !moved {
+		return nil, false
+	}
+
+	applications, _ := config["applications"].([]interface{})
+	found := false
+	for _, v := range applications {
+		if v == application {
+			found = true
+			break
+		}
+	}
+	if !found {
+		config["applications"] = append(applications, application)
+	}
+
+	return map[string]interface{}{
+		"job":         []interface{}{map[string]interface{}{"type": "upsertProject", "project": project}},
+		"application": "spinnaker",
+		"description": fmt.Sprintf("Move pipeline %s of project %s to %s", pipelineID, project["name"], application),
+	}, true
+}
+
 // CreateProject creates passed project
 func CreateProject(client *gate.GatewayClient, upsertProjectTask UpsertApplicationTask) error {
 	ref, _, err := client.TaskControllerApi.TaskUsingPOST1(client.Context, upsertProjectTask)
 	if err != nil {
 		return err
 	}
-	return orca_tasks.WaitForSuccessfulTask(client, ref)
+	return WaitForSuccessfulTask(client, ref)
 }
 
 // DeleteProject deletes a project by project name
@@ -144,7 +215,7 @@ func DeleteProject(client *gate.GatewayClient, id string, projectName string) er
 		"description": fmt.Sprintf("Delete project id: %s", id),
 	}
 
-	_, resp, err := client.TaskControllerApi.TaskUsingPOST1(client.Context, deleteAppTask)
+	ref, resp, err := client.TaskControllerApi.TaskUsingPOST1(client.Context, deleteAppTask)
 	if err != nil {
 		return err
 	}
@@ -153,7 +224,7 @@ func DeleteProject(client *gate.GatewayClient, id string, projectName string) er
 		return fmt.Errorf("Encountered an error deleting application, status code: %d", resp.StatusCode)
 	}
 
-	return nil
+	return WaitForSuccessfulTask(client, ref)
 }
 
 func convToMapArray(inputs []interface{}) []map[string]interface{} {
@@ -0,0 +1,213 @@
+package api
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+const (
+	DefaultRetryMaxAttempts = 4
+	DefaultRetryBaseDelay   = 500 * time.Millisecond
+	DefaultRetryMaxDelay    = 30 * time.Second
+)
+
+// RetryConfig is the retry policy of the requests to Gate failing with a
+// transient error
+type RetryConfig struct {
+	// MaxAttempts is the number of times a request is sent, 1 disables retries
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, doubled on each retry
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between two attempts, including Retry-After
+	MaxDelay time.Duration
+}
+
+// DefaultRetryConfig returns the retry policy used when none is configured
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxAttempts: DefaultRetryMaxAttempts,
+		BaseDelay:   DefaultRetryBaseDelay,
+		MaxDelay:    DefaultRetryMaxDelay,
+	}
+}
+
+// retryTransport sends again requests failing with a transient error: a
+// dropped connection, 429, 502, 503 or 504. Only idempotent requests and
+// Orca task submissions are retried, the latter only when Gate provably did
+// not process them.
+type retryTransport struct {
+	base   http.RoundTripper
+	config RetryConfig
+	// sleep waits for d or until the request is cancelled, replaced in tests
+	sleep func(req *http.Request, d time.Duration) error
+}
+
+func newRetryTransport(base http.RoundTripper, config RetryConfig) http.RoundTripper {
+	if config.MaxAttempts <= 1 {
+		return base
+	}
+
+	return &retryTransport{
+		base:   base,
+		config: config,
+		sleep:  sleepRequest,
+	}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isRetryableRequest(req) {
+		return t.base.RoundTrip(req)
+	}
+
+	for attempt := 1; ; attempt++ {
+		retry := req
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			retry = req.Clone(req.Context())
+			retry.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(retry)
+		if attempt >= t.config.MaxAttempts || !isTransientFailure(req, resp, err) {
+			return resp, err
+		}
+
+		delay := t.backoff(attempt, resp)
+		fields := map[string]interface{}{
+			"method":       req.Method,
+			"path":         req.URL.Path,
+			"delay":        delay.String(),
+			"attempt":      attempt,
+			"max_attempts": t.config.MaxAttempts,
+		}
+		if err != nil {
+			fields["error"] = err.Error()
+		} else {
+			fields["status"] = resp.Status
+			resp.Body.Close()
+		}
+		tflog.SubsystemDebug(req.Context(), LogGate, "Request to Gate failed, retrying", fields)
+
+		if err := t.sleep(req, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// backoff returns the delay before the next attempt: the Retry-After of the
+// response when there is one, or else an exponential delay with jitter
+func (t *retryTransport) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return minDuration(delay, t.config.MaxDelay)
+		}
+	}
+
+	delay := t.config.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > t.config.MaxDelay {
+		delay = t.config.MaxDelay
+	}
+
+	// Half of the delay is random so that the clients dropped by the same
+	// rollout do not all come back at once
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+func isRetryableRequest(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	case http.MethodPost:
+		// Orca tasks are not idempotent, a savePipeline without an id creates
+		// a new pipeline each time: isTransientFailure only retries them when
+		// Gate did not process them
+		return strings.HasSuffix(strings.TrimSuffix(req.URL.Path, "/"), "/tasks")
+	}
+
+	return false
+}
+
+func isTransientFailure(req *http.Request, resp *http.Response, err error) bool {
+	if req.Method == http.MethodPost {
+		return isUnprocessedFailure(req, resp, err)
+	}
+
+	if err != nil {
+		return req.Context().Err() == nil
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+// isUnprocessedFailure tells whether a failure proves that Gate never processed
+// the request: the connection could not be opened, so nothing was written, or
+// Gate turned the request down with a 429 or 503 asking to come back later
+func isUnprocessedFailure(req *http.Request, resp *http.Response, err error) bool {
+	if err != nil {
+		var opErr *net.OpError
+		return req.Context().Err() == nil && errors.As(err, &opErr) && opErr.Op == "dial"
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		_, ok := parseRetryAfter(resp.Header.Get("Retry-After"))
+		return ok
+	}
+
+	return false
+}
+
+// parseRetryAfter reads a Retry-After header in seconds or as an HTTP date
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(v); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+
+	return 0, false
+}
+
+func sleepRequest(req *http.Request, d time.Duration) error {
+	return sleepContext(req.Context(), d)
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
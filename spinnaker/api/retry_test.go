@@ -0,0 +1,220 @@
+package api
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestFlakyGate returns a Gate stand-in which fails the first requests
+// with fail, and records the bodies of the requests it receives
+func newTestFlakyGate(t *testing.T, failures int, fail func(w http.ResponseWriter)) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	bodies := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		n := len(bodies)
+		mu.Unlock()
+
+		if n <= failures {
+			fail(w)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, bodies...)
+	}
+}
+
+func newTestRetryClient(t *testing.T, delays *[]time.Duration) *http.Client {
+	var mu sync.Mutex
+	transport := newRetryTransport(http.DefaultTransport.(*http.Transport).Clone(), RetryConfig{
+		MaxAttempts: 3,
+		BaseDelay:   100 * time.Millisecond,
+		MaxDelay:    time.Second,
+	}).(*retryTransport)
+	transport.sleep = func(req *http.Request, d time.Duration) error {
+		mu.Lock()
+		defer mu.Unlock()
+		*delays = append(*delays, d)
+		return nil
+	}
+
+	return &http.Client{Transport: transport}
+}
+
+func TestRetryTransport(t *testing.T) {
+	unavailable := func(w http.ResponseWriter) { w.WriteHeader(http.StatusServiceUnavailable) }
+	dropped := func(w http.ResponseWriter) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}
+	badRequest := func(w http.ResponseWriter) { w.WriteHeader(http.StatusBadRequest) }
+	throttled := func(w http.ResponseWriter) {
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}
+
+	tcs := map[string]struct {
+		method   string
+		path     string
+		failures int
+		fail     func(w http.ResponseWriter)
+		status   int
+		attempts int
+	}{
+		"retry read on 503":            {http.MethodGet, "/applications", 2, unavailable, http.StatusOK, 3},
+		"retry read on dropped conn":   {http.MethodGet, "/applications", 1, dropped, http.StatusOK, 2},
+		"retry task submission":        {http.MethodPost, "/tasks", 1, throttled, http.StatusOK, 2},
+		"no retry task on 503":         {http.MethodPost, "/tasks", 1, unavailable, http.StatusServiceUnavailable, 1},
+		"give up after max attempts":   {http.MethodGet, "/applications", 5, unavailable, http.StatusServiceUnavailable, 3},
+		"no retry on client error":     {http.MethodGet, "/applications", 1, badRequest, http.StatusBadRequest, 1},
+		"no retry on pipeline trigger": {http.MethodPost, "/pipelines/start", 1, unavailable, http.StatusServiceUnavailable, 1},
+		"no retry on canary run":       {http.MethodPost, "/v2/canaries/canary/x", 1, unavailable, http.StatusServiceUnavailable, 1},
+	}
+
+	for n, tc := range tcs {
+		tc := tc
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			gate, bodies := newTestFlakyGate(t, tc.failures, tc.fail)
+			delays := []time.Duration{}
+			client := newTestRetryClient(t, &delays)
+
+			req, err := http.NewRequest(tc.method, gate.URL+tc.path, strings.NewReader(`{"job": []}`))
+			if err != nil {
+				t.Fatalf("failed: %v", err)
+			}
+
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("failed: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tc.status {
+				t.Fatalf("expected status %d, got %d", tc.status, resp.StatusCode)
+			}
+
+			got := bodies()
+			if len(got) != tc.attempts {
+				t.Fatalf("expected %d attempts, got %d", tc.attempts, len(got))
+			}
+
+			for _, body := range got {
+				if body != `{"job": []}` {
+					t.Fatalf("expected the body to be sent again, got %q", body)
+				}
+			}
+
+			for _, d := range delays {
+				if d < 50*time.Millisecond || d > time.Second {
+					t.Fatalf("unexpected delay %s", d)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryTransportTaskDropped(t *testing.T) {
+	// The task may have been processed before the connection dropped
+	gate, bodies := newTestFlakyGate(t, 1, func(w http.ResponseWriter) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	})
+	delays := []time.Duration{}
+	client := newTestRetryClient(t, &delays)
+
+	resp, err := client.Post(gate.URL+"/tasks", "application/json", strings.NewReader(`{"job": []}`))
+	if err == nil {
+		resp.Body.Close()
+		t.Fatalf("expected the dropped connection to fail the request")
+	}
+
+	if got := bodies(); len(got) != 1 {
+		t.Fatalf("expected 1 attempt, got %d", len(got))
+	}
+}
+
+func TestRetryTransportTaskRefused(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	delays := []time.Duration{}
+	client := newTestRetryClient(t, &delays)
+
+	resp, err := client.Post("http://"+addr+"/tasks", "application/json", strings.NewReader(`{"job": []}`))
+	if err == nil {
+		resp.Body.Close()
+		t.Fatalf("expected the refused connection to fail the request")
+	}
+
+	if len(delays) != 2 {
+		t.Fatalf("expected 2 retries, got %d", len(delays))
+	}
+}
+
+func TestRetryTransportRetryAfter(t *testing.T) {
+	gate, _ := newTestFlakyGate(t, 1, func(w http.ResponseWriter) {
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	delays := []time.Duration{}
+	client := newTestRetryClient(t, &delays)
+
+	resp, err := client.Get(gate.URL)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	resp.Body.Close()
+
+	// Retry-After is capped by the max delay
+	if len(delays) != 1 || delays[0] != time.Second {
+		t.Fatalf("expected a single delay of 1s, got %v", delays)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tcs := map[string]struct {
+		input      string
+		shouldPass bool
+	}{
+		"pass with seconds": {"120", true},
+		"pass with date":    {time.Now().Add(time.Minute).UTC().Format(http.TimeFormat), true},
+		"fail with empty":   {"", false},
+		"fail with garbage": {"soon", false},
+	}
+
+	for n, tc := range tcs {
+		tc := tc
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			_, ok := parseRetryAfter(tc.input)
+			if ok != tc.shouldPass {
+				t.Fatalf("expected %v, got %v", tc.shouldPass, ok)
+			}
+		})
+	}
+}
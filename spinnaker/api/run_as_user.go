@@ -0,0 +1,41 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	gate "github.com/spinnaker/spin/cmd/gateclient"
+)
+
+// RunAsUserHeader is the header Fiat reads the impersonated user from
+const RunAsUserHeader = "X-SPINNAKER-USER"
+
+type runAsUserKey struct{}
+
+// WithRunAsUser returns a copy of the client whose requests are made as user,
+// the client is returned as is when user is empty
+func WithRunAsUser(client *gate.GatewayClient, user string) *gate.GatewayClient {
+	if user == "" {
+		return client
+	}
+
+	c := *client
+	c.Context = context.WithValue(client.Context, runAsUserKey{}, user)
+	return &c
+}
+
+// runAsUserTransport sets the run as user header from the context of the request
+type runAsUserTransport struct {
+	base http.RoundTripper
+}
+
+func (t *runAsUserTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	user, ok := req.Context().Value(runAsUserKey{}).(string)
+	if !ok || user == "" {
+		return t.base.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set(RunAsUserHeader, user)
+	return t.base.RoundTrip(req)
+}
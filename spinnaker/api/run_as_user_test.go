@@ -0,0 +1,61 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestWithRunAsUser(t *testing.T) {
+	var mu sync.Mutex
+	users := []string{}
+	gate := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		users = append(users, r.Header.Get(RunAsUserHeader))
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"version": "1.0.0"}`)
+	}))
+	t.Cleanup(gate.Close)
+
+	lastUser := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return users[len(users)-1]
+	}
+
+	cfg := testClientConfig(t, gate.URL)
+	cfg.RunAsUser = "provider-svc"
+	client, err := NewGateClient(cfg)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	if got := lastUser(); got != "provider-svc" {
+		t.Fatalf("expected requests as provider-svc, got %q", got)
+	}
+
+	teamClient := WithRunAsUser(client, "team-svc")
+	if _, _, err := teamClient.VersionControllerApi.GetVersionUsingGET(teamClient.Context); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	if got := lastUser(); got != "team-svc" {
+		t.Fatalf("expected requests as team-svc, got %q", got)
+	}
+
+	if _, _, err := client.VersionControllerApi.GetVersionUsingGET(client.Context); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	if got := lastUser(); got != "provider-svc" {
+		t.Fatalf("expected the provider client to be unchanged, got %q", got)
+	}
+
+	if WithRunAsUser(client, "") != client {
+		t.Fatal("expected the client to be returned as is without user")
+	}
+}
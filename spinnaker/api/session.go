@@ -0,0 +1,171 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// BasicAuthConfig is the username and password used to log in to Gate's
+// /login form, as used by Gate with LDAP or basic authentication
+type BasicAuthConfig struct {
+	Username string
+	Password string
+}
+
+// sessionTransport logs in to Gate and keeps the session cookie in the jar of
+// the HTTP client. A request answered with 401 is retried once after logging
+// in again, so that a session expiring mid-apply does not fail the apply.
+type sessionTransport struct {
+	base     http.RoundTripper
+	jar      http.CookieJar
+	loginURL *url.URL
+	auth     *BasicAuthConfig
+
+	mu sync.Mutex
+	// generation is incremented on every login, so that concurrent requests
+	// rejected with the same expired session only log in once
+	generation int
+}
+
+func newSessionTransport(auth *BasicAuthConfig, endpoint string, httpClient *http.Client) (http.RoundTripper, error) {
+	if auth.Username == "" || auth.Password == "" {
+		return nil, fmt.Errorf("auth.basic requires a username and a password")
+	}
+
+	if httpClient.Jar == nil {
+		return nil, fmt.Errorf("auth.basic requires an HTTP client with a cookie jar")
+	}
+
+	loginURL, err := url.Parse(strings.TrimSuffix(endpoint, "/") + "/login")
+	if err != nil {
+		return nil, fmt.Errorf("could not parse Gate endpoint %s: %s", endpoint, err)
+	}
+
+	t := &sessionTransport{
+		base:     httpClient.Transport,
+		jar:      httpClient.Jar,
+		loginURL: loginURL,
+		auth:     auth,
+	}
+
+	if err := t.login(); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (t *sessionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	generation := t.generation
+	t.mu.Unlock()
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	// The body of the rejected request can only be sent again when it can
+	// be rewound, which is the case for the requests of the Gate client
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil
+	}
+
+	// The rejected response is read and closed before the login, so that it
+	// does not hold the slot of a limited client the login waits for
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.relogin(req.Context(), generation); err != nil {
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return resp, nil
+	}
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+
+	// The cookies of the request were set by the HTTP client before the
+	// login, they are replaced with the ones of the new session
+	retry.Header.Del("Cookie")
+	for _, cookie := range t.jar.Cookies(retry.URL) {
+		retry.AddCookie(cookie)
+	}
+
+	return t.base.RoundTrip(retry)
+}
+
+// relogin logs in again unless another request already did since generation
+func (t *sessionTransport) relogin(ctx context.Context, generation int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.generation != generation {
+		return nil
+	}
+
+	return t.loginLocked(ctx)
+}
+
+func (t *sessionTransport) login() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.loginLocked(context.Background())
+}
+
+// loginLocked posts the login form with ctx, the one of the request whose
+// session expired, so that the login stops with it
+func (t *sessionTransport) loginLocked(ctx context.Context) error {
+	client := &http.Client{
+		Transport: t.base,
+		Jar:       t.jar,
+		// Gate redirects to the UI after the login, the session cookie is
+		// all that is needed from the response
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	form := url.Values{}
+	form.Set("username", t.auth.Username)
+	form.Set("password", t.auth.Password)
+	form.Set("submit", "Login")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.loginURL.String(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not log in to Gate at %s: %s", t.loginURL, err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("could not log in to Gate at %s as %s: %s", t.loginURL, t.auth.Username, resp.Status)
+	}
+
+	// Spring Security redirects failed form logins to /login?error
+	if location, err := resp.Location(); err == nil && location.Query().Has("error") {
+		return fmt.Errorf("could not log in to Gate at %s as %s: bad credentials", t.loginURL, t.auth.Username)
+	}
+
+	t.generation++
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testSessionGate is a Gate stand-in with a /login form, which answers 401
+// to requests without a valid session cookie
+type testSessionGate struct {
+	mu       sync.Mutex
+	sessions map[string]bool
+	logins   int
+}
+
+func newTestSessionGate(t *testing.T) (*httptest.Server, *testSessionGate) {
+	g := &testSessionGate{sessions: map[string]bool{}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+
+		if r.URL.Path == "/login" {
+			if r.Method != http.MethodPost || r.FormValue("username") != "admin" || r.FormValue("password") != "secret" {
+				http.Redirect(w, r, "/login?error", http.StatusFound)
+				return
+			}
+
+			g.logins++
+			session := fmt.Sprintf("session-%d", g.logins)
+			g.sessions[session] = true
+			http.SetCookie(w, &http.Cookie{Name: "SESSION", Value: session, Path: "/"})
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+
+		cookie, err := r.Cookie("SESSION")
+		if err != nil || !g.sessions[cookie.Value] {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"version": "1.0.0"}`)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, g
+}
+
+func (g *testSessionGate) expireSessions() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.sessions = map[string]bool{}
+}
+
+func (g *testSessionGate) loginCount() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.logins
+}
+
+func TestNewGateClientBasicAuth(t *testing.T) {
+	gate, sessions := newTestSessionGate(t)
+
+	cfg := testClientConfig(t, gate.URL)
+	cfg.Basic = &BasicAuthConfig{Username: "admin", Password: "secret"}
+	client, err := NewGateClient(cfg)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	if got := sessions.loginCount(); got != 1 {
+		t.Fatalf("expected 1 login, got %d", got)
+	}
+
+	sessions.expireSessions()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := client.VersionControllerApi.GetVersionUsingGET(client.Context); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatalf("expected the request to be retried with a new session: %v", err)
+	}
+
+	if got := sessions.loginCount(); got != 2 {
+		t.Fatalf("expected the expired session to be renewed once, got %d logins", got)
+	}
+}
+
+func TestSessionTransportReloginWithLimit(t *testing.T) {
+	gate, sessions := newTestSessionGate(t)
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	httpClient := &http.Client{
+		Jar:       jar,
+		Transport: newLimitTransport(http.DefaultTransport, LimitConfig{ConcurrentRequests: 1}),
+	}
+	httpClient.Transport, err = newSessionTransport(&BasicAuthConfig{Username: "admin", Password: "secret"}, gate.URL, httpClient)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	sessions.expireSessions()
+
+	// The login waits for the slot of the rejected request, a hang ends
+	// with the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gate.URL+"/version", nil)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		t.Fatalf("expected the request to be retried with a new session: %v", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the request to be retried with a new session, got %s", resp.Status)
+	}
+	if got := sessions.loginCount(); got != 2 {
+		t.Fatalf("expected the expired session to be renewed, got %d logins", got)
+	}
+}
+
+func TestNewGateClientBasicAuthFailure(t *testing.T) {
+	gate, _ := newTestSessionGate(t)
+
+	tcs := map[string]*BasicAuthConfig{
+		"fail with bad credentials": {Username: "admin", Password: "wrong"},
+		"fail without password":     {Username: "admin"},
+	}
+
+	for n, tc := range tcs {
+		tc := tc
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			cfg := testClientConfig(t, gate.URL)
+			cfg.Basic = tc
+			if _, err := NewGateClient(cfg); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	gate "github.com/spinnaker/spin/cmd/gateclient"
+)
+
+const (
+	taskPollMinInterval = time.Second
+	taskPollMaxInterval = 20 * time.Second
+)
+
+var (
+	taskCompletedStatuses = []string{"SUCCEEDED", "STOPPED", "SKIPPED", "TERMINAL", "FAILED_CONTINUE", "CANCELED"}
+	taskSucceededStatuses = []string{"SUCCEEDED", "STOPPED", "SKIPPED"}
+)
+
+// WithContext returns a copy of the client whose requests are cancelled with
+// ctx and logged with its loggers, the values of the client context such as
+// the authentication are kept
+func WithContext(client *gate.GatewayClient, ctx context.Context) *gate.GatewayClient {
+	c := *client
+	c.Context = &clientContext{Context: ContextWithLogging(ctx), values: client.Context}
+	return &c
+}
+
+// clientContext is the cancellation of a CRUD context with the values of the
+// context the client was built with
+type clientContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c *clientContext) Value(key interface{}) interface{} {
+	if v := c.values.Value(key); v != nil {
+		return v
+	}
+	return c.Context.Value(key)
+}
+
+// WaitForSuccessfulTask polls the Orca task of taskRef until it completes, and
+// stops waiting once the context of the client is done
+func WaitForSuccessfulTask(client *gate.GatewayClient, taskRef map[string]interface{}) error {
+	id, err := taskIDFromRef(taskRef)
+	if err != nil {
+		return err
+	}
+	tflog.SubsystemDebug(client.Context, LogOrca, "Waiting for task", map[string]interface{}{"task_id": id})
+
+	for attempt := 1; ; attempt++ {
+		task, resp, err := client.TaskControllerApi.GetTaskUsingGET1(client.Context, id)
+		if err := client.Context.Err(); err != nil {
+			return fmt.Errorf("stopped waiting for task %s: %w", id, err)
+		}
+		if err != nil {
+			return fmt.Errorf("could not get task %s: %s", id, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("could not get task %s, status code: %d", id, resp.StatusCode)
+		}
+
+		status, _ := task["status"].(string)
+		tflog.SubsystemTrace(client.Context, LogOrca, "Polled task", map[string]interface{}{"task_id": id, "status": status, "attempt": attempt})
+		if containsString(taskCompletedStatuses, status) {
+			if containsString(taskSucceededStatuses, status) {
+				tflog.SubsystemDebug(client.Context, LogOrca, "Task succeeded", map[string]interface{}{"task_id": id, "status": status})
+				return nil
+			}
+
+			taskErr := newTaskError(client, id, task)
+			tflog.SubsystemDebug(client.Context, LogOrca, "Task failed", map[string]interface{}{
+				"task_id": id,
+				"status":  status,
+				"step":    taskErr.Step,
+				"message": taskErr.Message,
+			})
+			return taskErr
+		}
+
+		if err := sleepContext(client.Context, taskPollInterval(attempt)); err != nil {
+			return fmt.Errorf("stopped waiting for task %s: %w", id, err)
+		}
+	}
+}
+
+// taskPollInterval grows quadratically, like spin does, up to taskPollMaxInterval
+func taskPollInterval(attempt int) time.Duration {
+	interval := time.Duration(attempt*attempt) * taskPollMinInterval
+	if interval > taskPollMaxInterval {
+		return taskPollMaxInterval
+	}
+	return interval
+}
+
+func taskIDFromRef(taskRef map[string]interface{}) (string, error) {
+	ref, ok := taskRef["ref"].(string)
+	if !ok || ref == "" {
+		return "", fmt.Errorf("no task reference in the response of Gate: %v", taskRef)
+	}
+
+	parts := strings.Split(ref, "/")
+	return parts[len(parts)-1], nil
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func containsString(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// TaskError is an Orca task which did not succeed
+type TaskError struct {
+	ID     string
+	Status string
+	// Step is the name of the step which failed, if known
+	Step string
+	// Message is the exception of the task, if any
+	Message string
+	// URL is the task in Gate
+	URL string
+}
+
+func (e *TaskError) Error() string {
+	msg := fmt.Sprintf("task %s ended with status %s", e.ID, e.Status)
+	if e.Step != "" {
+		msg += fmt.Sprintf(" in step %s", e.Step)
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+func newTaskError(client *gate.GatewayClient, id string, task map[string]interface{}) *TaskError {
+	status, _ := task["status"].(string)
+	return &TaskError{
+		ID:      id,
+		Status:  status,
+		Step:    taskFailedStep(task),
+		Message: taskFailureMessage(task),
+		URL:     strings.TrimSuffix(client.Config.Gate.Endpoint, "/") + "/tasks/" + id,
+	}
+}
+
+// taskFailedStep returns the name of the first step which did not succeed
+func taskFailedStep(task map[string]interface{}) string {
+	steps, _ := task["steps"].([]interface{})
+	for _, s := range steps {
+		step, _ := s.(map[string]interface{})
+		status, _ := step["status"].(string)
+		if containsString(taskCompletedStatuses, status) && !containsString(taskSucceededStatuses, status) {
+			name, _ := step["name"].(string)
+			return name
+		}
+	}
+	return ""
+}
+
+// taskFailureMessage looks for the exception of the task where Deck does:
+// the exception variable, then the exceptions of the Clouddriver tasks
+func taskFailureMessage(task map[string]interface{}) string {
+	variables := map[string]interface{}{}
+	if vs, ok := task["variables"].([]interface{}); ok {
+		for _, v := range vs {
+			variable, _ := v.(map[string]interface{})
+			if key, ok := variable["key"].(string); ok {
+				variables[key] = variable["value"]
+			}
+		}
+	} else if taskContext, ok := task["context"].(map[string]interface{}); ok {
+		variables = taskContext
+	}
+
+	if exception, ok := variables["exception"].(map[string]interface{}); ok {
+		if msg := exceptionMessage(exception); msg != "" {
+			return msg
+		}
+	}
+
+	katoTasks, _ := variables["kato.tasks"].([]interface{})
+	for _, t := range katoTasks {
+		katoTask, _ := t.(map[string]interface{})
+		if exception, ok := katoTask["exception"].(map[string]interface{}); ok {
+			if msg, _ := exception["message"].(string); msg != "" {
+				return msg
+			}
+		}
+	}
+
+	return ""
+}
+
+func exceptionMessage(exception map[string]interface{}) string {
+	details, _ := exception["details"].(map[string]interface{})
+	if errs, ok := details["errors"].([]interface{}); ok && len(errs) > 0 {
+		msgs := make([]string, 0, len(errs))
+		for _, e := range errs {
+			msgs = append(msgs, fmt.Sprint(e))
+		}
+		return strings.Join(msgs, "; ")
+	}
+
+	if msg, _ := details["error"].(string); msg != "" {
+		return msg
+	}
+
+	msg, _ := exception["message"].(string)
+	return msg
+}
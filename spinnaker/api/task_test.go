@@ -0,0 +1,174 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestTaskGate returns a Gate stand-in whose Orca tasks have status, and
+// which fails the request when it is not made as user
+func newTestTaskGate(t *testing.T, status, user string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if !strings.HasPrefix(r.URL.Path, "/tasks/") {
+			fmt.Fprint(w, `{"version": "1.0.0"}`)
+			return
+		}
+
+		if got := r.Header.Get(RunAsUserHeader); got != user {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		fmt.Fprintf(w, `{"id": "%s", "status": "%s"}`, strings.TrimPrefix(r.URL.Path, "/tasks/"), status)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestWaitForSuccessfulTask(t *testing.T) {
+	tcs := map[string]struct {
+		status     string
+		shouldPass bool
+	}{
+		"pass with succeeded": {"SUCCEEDED", true},
+		"fail with terminal":  {"TERMINAL", false},
+		"fail with canceled":  {"CANCELED", false},
+	}
+
+	for n, tc := range tcs {
+		tc := tc
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			gate := newTestTaskGate(t, tc.status, "team-svc")
+			client, err := NewGateClient(testClientConfig(t, gate.URL))
+			if err != nil {
+				t.Fatalf("failed: %v", err)
+			}
+
+			// The values of the client context are kept by WithContext
+			client = WithContext(WithRunAsUser(client, "team-svc"), context.Background())
+			err = WaitForSuccessfulTask(client, map[string]interface{}{"ref": "/tasks/01ABC"})
+			var taskErr *TaskError
+			if err != nil && !errors.As(err, &taskErr) {
+				t.Fatalf("expected a task error, got %v", err)
+			}
+			if err != nil && tc.shouldPass {
+				t.Fatalf("failed: %v", err)
+			}
+			if err == nil && !tc.shouldPass {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestWaitForSuccessfulTaskCancelled(t *testing.T) {
+	gate := newTestTaskGate(t, "RUNNING", "")
+	client, err := NewGateClient(testClientConfig(t, gate.URL))
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = WaitForSuccessfulTask(WithContext(client, ctx), map[string]interface{}{"ref": "/tasks/01ABC"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the wait to stop with the context, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > taskPollMinInterval {
+		t.Fatalf("expected the wait to stop right away, took %s", elapsed)
+	}
+}
+
+func TestTaskPollInterval(t *testing.T) {
+	if got := taskPollInterval(1); got != time.Second {
+		t.Fatalf("expected 1s, got %s", got)
+	}
+
+	if got := taskPollInterval(10); got != taskPollMaxInterval {
+		t.Fatalf("expected %s, got %s", taskPollMaxInterval, got)
+	}
+}
+
+func TestNewTaskError(t *testing.T) {
+	gate := newTestTaskGate(t, "SUCCEEDED", "")
+	client, err := NewGateClient(testClientConfig(t, gate.URL))
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	tcs := map[string]struct {
+		task     map[string]interface{}
+		step     string
+		expected string
+	}{
+		"exception errors": {
+			map[string]interface{}{
+				"status": "TERMINAL",
+				"steps": []interface{}{
+					map[string]interface{}{"name": "validateApplication", "status": "SUCCEEDED"},
+					map[string]interface{}{"name": "upsertApplication", "status": "TERMINAL"},
+				},
+				"variables": []interface{}{
+					map[string]interface{}{"key": "exception", "value": map[string]interface{}{
+						"details": map[string]interface{}{"errors": []interface{}{"Application already exists", "Email is invalid"}},
+					}},
+				},
+			},
+			"upsertApplication",
+			"Application already exists; Email is invalid",
+		},
+		"exception error": {
+			map[string]interface{}{
+				"status": "TERMINAL",
+				"variables": []interface{}{
+					map[string]interface{}{"key": "exception", "value": map[string]interface{}{
+						"details": map[string]interface{}{"error": "Access denied to application"},
+					}},
+				},
+			},
+			"",
+			"Access denied to application",
+		},
+		"clouddriver task exception": {
+			map[string]interface{}{
+				"status": "TERMINAL",
+				"variables": []interface{}{
+					map[string]interface{}{"key": "kato.tasks", "value": []interface{}{
+						map[string]interface{}{"exception": map[string]interface{}{"message": "Account prod not found"}},
+					}},
+				},
+			},
+			"",
+			"Account prod not found",
+		},
+	}
+
+	for n, tc := range tcs {
+		tc := tc
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			taskErr := newTaskError(client, "01ABC", tc.task)
+			if taskErr.Step != tc.step {
+				t.Fatalf("expected step %q, got %q", tc.step, taskErr.Step)
+			}
+			if taskErr.Message != tc.expected {
+				t.Fatalf("expected message %q, got %q", tc.expected, taskErr.Message)
+			}
+			if taskErr.URL != gate.URL+"/tasks/01ABC" {
+				t.Fatalf("expected link to the task in Gate, got %q", taskErr.URL)
+			}
+		})
+	}
+}
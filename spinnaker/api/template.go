@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"github.com/mitchellh/mapstructure"
 	gate "github.com/spinnaker/spin/cmd/gateclient"
 )
@@ -22,6 +23,7 @@ func CreatePipelineTemplate(client *gate.GatewayClient, template interface{}) er
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
 		return fmt.Errorf("Encountered an error saving template, status code: %d\n", resp.StatusCode)
 	}
+	tflog.SubsystemDebug(client.Context, LogFront50, "Created pipeline template")
 
 	return nil
 }
@@ -51,6 +53,7 @@ func GetPipelineTemplate(client *gate.GatewayClient, templateID string, dest int
 	if err := mapstructure.Decode(successPayload, dest); err != nil {
 		return err
 	}
+	tflog.SubsystemTrace(client.Context, LogFront50, "Read pipeline template", map[string]interface{}{"template_id": templateID})
 
 	return nil
 }
@@ -66,6 +69,7 @@ func DeletePipelineTemplate(client *gate.GatewayClient, templateID string) error
 			templateID,
 			resp.StatusCode)
 	}
+	tflog.SubsystemDebug(client.Context, LogFront50, "Deleted pipeline template", map[string]interface{}{"template_id": templateID})
 
 	return nil
 }
@@ -81,6 +85,7 @@ func UpdatePipelineTemplate(client *gate.GatewayClient, templateID string, templ
 			templateID,
 			resp.StatusCode)
 	}
+	tflog.SubsystemDebug(client.Context, LogFront50, "Updated pipeline template", map[string]interface{}{"template_id": templateID})
 
 	return nil
 }
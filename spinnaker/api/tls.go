@@ -0,0 +1,80 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const pemPrefix = "-----BEGIN"
+
+// applyTLSConfig sets the client certificate and the CA bundle of the
+// ClientConfig on the transport
+func applyTLSConfig(transport *http.Transport, cfg ClientConfig) error {
+	if cfg.ClientCert == "" && cfg.ClientKey == "" && cfg.CABundle == "" {
+		return nil
+	}
+
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	tlsConfig := transport.TLSClientConfig
+	if tlsConfig.MinVersion == 0 {
+		tlsConfig.MinVersion = tls.VersionTLS12
+	}
+
+	if (cfg.ClientCert == "") != (cfg.ClientKey == "") {
+		return fmt.Errorf("client_cert and client_key must be set together")
+	}
+
+	if cfg.ClientCert != "" {
+		certPEM, err := readPEM(cfg.ClientCert)
+		if err != nil {
+			return fmt.Errorf("could not read client_cert: %s", err)
+		}
+
+		keyPEM, err := readPEM(cfg.ClientKey)
+		if err != nil {
+			return fmt.Errorf("could not read client_key: %s", err)
+		}
+
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return fmt.Errorf("could not load client certificate: %s", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if cfg.CABundle != "" {
+		bundle, err := readPEM(cfg.CABundle)
+		if err != nil {
+			return fmt.Errorf("could not read ca_bundle: %s", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM(bundle) {
+			return fmt.Errorf("no certificate found in ca_bundle")
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	return nil
+}
+
+// readPEM returns v when it is PEM content, or else the content of the file at path v
+func readPEM(v string) ([]byte, error) {
+	if strings.Contains(v, pemPrefix) {
+		return []byte(v), nil
+	}
+
+	return os.ReadFile(v)
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCertificate struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func newTestCertificate(t *testing.T, template *x509.Certificate, parent *testCertificate) *testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	return &testCertificate{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func newTestTemplate(serial int64, name string) *x509.Certificate {
+	return &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-1 * time.Hour),
+		NotAfter:     time.Now().Add(1 * time.Hour),
+	}
+}
+
+// newTestMTLSGate returns a Gate stand-in behind TLS, signed by an internal
+// CA, which requires a client certificate signed by the same CA
+func newTestMTLSGate(t *testing.T) (*httptest.Server, *testCertificate, *testCertificate) {
+	caTemplate := newTestTemplate(1, "internal-ca")
+	caTemplate.IsCA = true
+	caTemplate.BasicConstraintsValid = true
+	caTemplate.KeyUsage = x509.KeyUsageCertSign
+	ca := newTestCertificate(t, caTemplate, nil)
+
+	serverTemplate := newTestTemplate(2, "gate")
+	serverTemplate.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	serverTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	server := newTestCertificate(t, serverTemplate, ca)
+
+	clientTemplate := newTestTemplate(3, "terraform")
+	clientTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	client := newTestCertificate(t, clientTemplate, ca)
+
+	serverCert, err := tls.X509KeyPair(server.certPEM, server.keyPEM)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	gate := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"version": "1.0.0"}`)
+	}))
+	gate.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	}
+	gate.StartTLS()
+	t.Cleanup(gate.Close)
+
+	return gate, ca, client
+}
+
+func TestNewGateClientMutualTLS(t *testing.T) {
+	gate, ca, client := newTestMTLSGate(t)
+
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "client.crt")
+	keyPath := filepath.Join(dir, "client.key")
+	if err := os.WriteFile(certPath, client.certPEM, 0600); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	if err := os.WriteFile(keyPath, client.keyPEM, 0600); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	tcs := map[string]struct {
+		clientCert string
+		clientKey  string
+		caBundle   string
+		shouldPass bool
+	}{
+		"pass with PEM content":      {string(client.certPEM), string(client.keyPEM), string(ca.certPEM), true},
+		"pass with paths":            {certPath, keyPath, string(ca.certPEM), true},
+		"fail without client cert":   {"", "", string(ca.certPEM), false},
+		"fail without ca bundle":     {string(client.certPEM), string(client.keyPEM), "", false},
+		"fail with cert without key": {string(client.certPEM), "", string(ca.certPEM), false},
+		"fail with invalid bundle":   {string(client.certPEM), string(client.keyPEM), "not a certificate", false},
+	}
+
+	for n, tc := range tcs {
+		tc := tc
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			cfg := testClientConfig(t, gate.URL)
+			cfg.ClientCert = tc.clientCert
+			cfg.ClientKey = tc.clientKey
+			cfg.CABundle = tc.caBundle
+
+			_, err := NewGateClient(cfg)
+			if err != nil && tc.shouldPass {
+				t.Fatalf("failed: %v", err)
+			}
+			if err == nil && !tc.shouldPass {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
@@ -1,11 +1,15 @@
 package spinnaker
 
 import (
+	"context"
 	"strconv"
 	"testing"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/himanhsugusain/terraform-provider-spinnaker/spinnaker/fakegate"
 )
 
 func TestAccDataSourceSpinnakerApplication_basic(t *testing.T) {
@@ -14,12 +18,12 @@ func TestAccDataSourceSpinnakerApplication_basic(t *testing.T) {
 	resource.ParallelTest(t, resource.TestCase{
 		PreCheck:     func() { testAccPreCheck(t) },
 		Providers:    testAccProviders,
-		CheckDestroy: testAccCheckSpinnakerApplicatioDestroy(t, resourceName),
+		CheckDestroy: testAccCheckSpinnakerApplicatioDestroy(t, testAccProvider, resourceName),
 		Steps: []resource.TestStep{
 			{
 				Config: testAccSpinnakerApplication_basic(rName),
 				Check: resource.ComposeTestCheckFunc(
-					testAccCheckSpinnakerApplicationExists(resourceName),
+					testAccCheckSpinnakerApplicationExists(testAccProvider, resourceName),
 					resource.TestCheckResourceAttr(resourceName, "name", rName),
 					resource.TestCheckResourceAttr(resourceName, "email", "acceptance@test.com"),
 					resource.TestCheckResourceAttr(resourceName, "instance_port", strconv.Itoa(defaultInstancePort)),
@@ -36,12 +40,12 @@ func TestAccDataSourceSpinnakerApplication_instancePort(t *testing.T) {
 	resource.ParallelTest(t, resource.TestCase{
 		PreCheck:     func() { testAccPreCheck(t) },
 		Providers:    testAccProviders,
-		CheckDestroy: testAccCheckSpinnakerApplicatioDestroy(t, resourceName),
+		CheckDestroy: testAccCheckSpinnakerApplicatioDestroy(t, testAccProvider, resourceName),
 		Steps: []resource.TestStep{
 			{
 				Config: testAccSpinnakerApplication_instancePort(rName, rPort),
 				Check: resource.ComposeTestCheckFunc(
-					testAccCheckSpinnakerApplicationExists(resourceName),
+					testAccCheckSpinnakerApplicationExists(testAccProvider, resourceName),
 					resource.TestCheckResourceAttr(resourceName, "name", rName),
 					resource.TestCheckResourceAttr(resourceName, "email", "acceptance@test.com"),
 					resource.TestCheckResourceAttr(resourceName, "instance_port", strconv.Itoa(rPort)),
@@ -58,12 +62,12 @@ func TestAccDataSourceSpinnakerApplication_cloudProviders(t *testing.T) {
 	resource.ParallelTest(t, resource.TestCase{
 		PreCheck:     func() { testAccPreCheck(t) },
 		Providers:    testAccProviders,
-		CheckDestroy: testAccCheckSpinnakerApplicatioDestroy(t, resourceName),
+		CheckDestroy: testAccCheckSpinnakerApplicatioDestroy(t, testAccProvider, resourceName),
 		Steps: []resource.TestStep{
 			{
 				Config: testAccSpinnakerApplication_cloudProvider(rName, cloudProvider),
 				Check: resource.ComposeTestCheckFunc(
-					testAccCheckSpinnakerApplicationExists(resourceName),
+					testAccCheckSpinnakerApplicationExists(testAccProvider, resourceName),
 					resource.TestCheckResourceAttr(resourceName, "name", rName),
 					resource.TestCheckResourceAttr(resourceName, "email", "acceptance@test.com"),
 					resource.TestCheckResourceAttr(resourceName, "instance_port", strconv.Itoa(defaultInstancePort)),
@@ -73,3 +77,29 @@ func TestAccDataSourceSpinnakerApplication_cloudProviders(t *testing.T) {
 		},
 	})
 }
+
+// The data source shares the read of the resource, without run_as_user
+func TestDataSourceSpinnakerApplicationRead(t *testing.T) {
+	gate := fakegate.New()
+	meta := testUnitMeta(gate)
+	r := resourceSpinnakerApplication()
+	app := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"name":            "tf-unit-test",
+		"email":           "acceptance@test.com",
+		"cloud_providers": []interface{}{"kubernetes"},
+	})
+	if diags := r.CreateContext(context.Background(), app, meta); diags.HasError() {
+		t.Fatalf("failed: %v", diags)
+	}
+
+	d := schema.TestResourceDataRaw(t, datasourceApplication().Schema, map[string]interface{}{
+		"name":  "tf-unit-test",
+		"email": "acceptance@test.com",
+	})
+	if diags := datasourceApplication().ReadContext(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("failed: %v", diags)
+	}
+	if got := d.Get("cloud_providers").([]interface{}); len(got) != 1 || got[0] != "kubernetes" {
+		t.Fatalf("expected the cloud providers of the application, got %v", got)
+	}
+}
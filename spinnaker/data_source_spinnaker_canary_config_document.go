@@ -0,0 +1,85 @@
+package spinnaker
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	api "github.com/himanhsugusain/terraform-provider-spinnaker/spinnaker/api"
+	"github.com/mitchellh/mapstructure"
+)
+
+func datasourceCanaryConfigDocument() *schema.Resource {
+	return &schema.Resource{
+		Description: "Provides an existing Spinnaker canary config as a Kayenta JSON document",
+		Schema: map[string]*schema.Schema{
+			"canary_config_id": {
+				Description: "Canary config id",
+				Type:        schema.TypeString,
+				Required:    true,
+			},
+			"name": {
+				Description: "Name of the canary config",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"description": {
+				Description: "Description of the canary config",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"applications": {
+				Description: "List of the application which the canary config belongs",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"config_json": {
+				Description: "Kayenta document of the canary config, without the attributes above and the timestamps",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+		},
+		ReadContext: datasourceCanaryConfigDocumentRead,
+	}
+}
+
+func datasourceCanaryConfigDocumentRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	clientConfig := meta.(gateConfig)
+	if err := clientConfig.require(ctx, api.CapabilityCanary); err != nil {
+		return diag.FromErr(err)
+	}
+	client := clientConfig.client.WithContext(ctx)
+	id := d.Get("canary_config_id").(string)
+
+	doc := map[string]interface{}{}
+	if err := client.GetCanaryConfig(id, &doc); err != nil {
+		return diag.FromErr(err)
+	}
+
+	configJSON, err := api.EncodeCanaryConfigJSON(doc)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	config := &canaryConfigRead{}
+	if err := mapstructure.Decode(doc, config); err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId(id)
+	if err := d.Set("name", config.Name); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("description", config.Description); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("applications", config.Applications); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("config_json", configJSON); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
@@ -28,6 +28,6 @@ func datasourcePipeline() *schema.Resource {
 				Computed: true,
 			},
 		},
-		Read: resourcePipelineRead,
+		ReadContext: resourcePipelineRead,
 	}
 }
@@ -0,0 +1,36 @@
+package spinnaker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/himanhsugusain/terraform-provider-spinnaker/spinnaker/fakegate"
+)
+
+// The data source shares the read of the resource, without run_as_user
+func TestDataSourceSpinnakerPipelineRead(t *testing.T) {
+	gate := fakegate.New()
+	meta := testUnitMeta(gate)
+	r := resourcePipeline()
+	pipeline := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"application": "tf-unit-test",
+		"name":        "deploy",
+		"pipeline":    `{"limitConcurrent": true, "stages": []}`,
+	})
+	if diags := r.CreateContext(context.Background(), pipeline, meta); diags.HasError() {
+		t.Fatalf("failed: %v", diags)
+	}
+
+	d := schema.TestResourceDataRaw(t, datasourcePipeline().Schema, map[string]interface{}{
+		"application": "tf-unit-test",
+		"name":        "deploy",
+	})
+	if diags := datasourcePipeline().ReadContext(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("failed: %v", diags)
+	}
+	if d.Id() != pipeline.Id() || d.Get("pipeline").(string) != `{"limitConcurrent":true,"stages":[]}` {
+		t.Fatalf("unexpected state after read: %v", d.State())
+	}
+}
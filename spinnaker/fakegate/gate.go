@@ -0,0 +1,606 @@
+// Package fakegate is an in-memory Gate implementing api.Client, so the
+// resources of the provider can be unit tested without a Spinnaker.
+package fakegate
+
+import (
+	"context"
+	b64 "encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/himanhsugusain/terraform-provider-spinnaker/spinnaker/api"
+)
+
+const (
+	taskSucceeded = "SUCCEEDED"
+	taskTerminal  = "TERMINAL"
+)
+
+// errConflict is returned for the entities which already exist
+var errConflict = errors.New("conflict")
+
+// Task is an Orca task submitted to the fake Gate
+type Task struct {
+	ID          string
+	Type        string
+	Application string
+	User        string
+	Status      string
+	// Message is the exception of a task which did not succeed
+	Message string
+}
+
+// Gate is the state of the fake Gate, shared by the clients returned by
+// WithRunAsUser and WithContext
+type Gate struct {
+	mu sync.Mutex
+
+	applications     map[string]map[string]interface{}
+	pipelines        map[string]map[string]interface{}
+	templates        map[string]map[string]interface{}
+	projects         map[string]map[string]interface{}
+	canaryConfigs    map[string]map[string]interface{}
+	canaryExecutions map[string]map[string]interface{}
+	tasks            []Task
+	failures         map[string]string
+	nextID           int
+
+	// CanaryScore is the score the canary analyses end with
+	CanaryScore float64
+}
+
+// client is a view of the Gate with the user and context of the requests
+type client struct {
+	gate *Gate
+	user string
+	ctx  context.Context
+}
+
+var _ api.Client = &client{}
+
+// New returns an empty fake Gate
+func New() *Gate {
+	return &Gate{
+		applications:     map[string]map[string]interface{}{},
+		pipelines:        map[string]map[string]interface{}{},
+		templates:        map[string]map[string]interface{}{},
+		projects:         map[string]map[string]interface{}{},
+		canaryConfigs:    map[string]map[string]interface{}{},
+		canaryExecutions: map[string]map[string]interface{}{},
+		failures:         map[string]string{},
+		CanaryScore:      100,
+	}
+}
+
+// Client returns an api.Client of the Gate
+func (g *Gate) Client() api.Client {
+	return &client{gate: g, ctx: context.Background()}
+}
+
+// Tasks returns the Orca tasks submitted so far
+func (g *Gate) Tasks() []Task {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return append([]Task(nil), g.tasks...)
+}
+
+// FailTasks makes the tasks with a job of jobType end as TERMINAL with the
+// exception message, an empty message makes them succeed again
+func (g *Gate) FailTasks(jobType, message string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if message == "" {
+		delete(g.failures, jobType)
+		return
+	}
+	g.failures[jobType] = message
+}
+
+// Pipelines returns the pipelines of the application
+func (g *Gate) Pipelines(applicationName string) []map[string]interface{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var pipelines []map[string]interface{}
+	for _, p := range g.pipelines {
+		if p["application"] == applicationName {
+			pipelines = append(pipelines, clone(p))
+		}
+	}
+	sort.Slice(pipelines, func(i, j int) bool {
+		return fmt.Sprint(pipelines[i]["name"]) < fmt.Sprint(pipelines[j]["name"])
+	})
+	return pipelines
+}
+
+func (g *Gate) newID() string {
+	g.nextID++
+	return fmt.Sprintf("%08d-fake", g.nextID)
+}
+
+func (c *client) WithRunAsUser(user string) api.Client {
+	return &client{gate: c.gate, user: user, ctx: c.ctx}
+}
+
+func (c *client) WithContext(ctx context.Context) api.Client {
+	return &client{gate: c.gate, user: c.user, ctx: ctx}
+}
+
+// lock checks the context of the request like a Gate call would and takes the
+// lock of the state
+func (c *client) lock() error {
+	if err := c.ctx.Err(); err != nil {
+		return err
+	}
+	c.gate.mu.Lock()
+	return nil
+}
+
+func (c *client) unlock() {
+	c.gate.mu.Unlock()
+}
+
+// runTask submits the task request and returns the error of the task, like
+// api.WaitForSuccessfulTask does
+func (c *client) runTask(task map[string]interface{}) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.unlock()
+
+	t, err := c.gate.submitTask(task, c.user)
+	if err != nil {
+		return err
+	}
+	if t.Status != taskSucceeded {
+		return &api.TaskError{
+			ID:      t.ID,
+			Status:  t.Status,
+			Step:    t.Type,
+			Message: t.Message,
+			URL:     "fakegate://tasks/" + t.ID,
+		}
+	}
+	return nil
+}
+
+// submitTask records the Orca task of a task request and runs its jobs right
+// away, a job whose type FailTasks made fail ends the task as TERMINAL. An
+// error is returned for the requests Gate would refuse.
+func (g *Gate) submitTask(task map[string]interface{}, user string) (Task, error) {
+	task = clone(task)
+	jobs, _ := task["job"].([]interface{})
+	if len(jobs) == 0 {
+		return Task{}, fmt.Errorf("no job in the task request")
+	}
+
+	application, _ := task["application"].(string)
+	t := Task{ID: g.newID(), Application: application, User: user, Status: taskSucceeded}
+	defer func() { g.tasks = append(g.tasks, t) }()
+
+	for _, j := range jobs {
+		job, _ := j.(map[string]interface{})
+		t.Type, _ = job["type"].(string)
+		if message, ok := g.failures[t.Type]; ok {
+			t.Status, t.Message = taskTerminal, message
+			return t, nil
+		}
+		if err := g.runJob(t.Type, job, user); err != nil {
+			t.Status, t.Message = taskTerminal, err.Error()
+			return t, nil
+		}
+	}
+
+	return t, nil
+}
+
+// runJob applies the job of an Orca task to the state
+func (g *Gate) runJob(jobType string, job map[string]interface{}, user string) error {
+	switch jobType {
+	case "createApplication", "updateApplication":
+		attributes, _ := job["application"].(map[string]interface{})
+		name, _ := attributes["name"].(string)
+		g.applications[name] = map[string]interface{}{
+			"name":       name,
+			"attributes": attributes,
+		}
+	case "deleteApplication":
+		app, _ := job["application"].(map[string]interface{})
+		name, _ := app["name"].(string)
+		delete(g.applications, name)
+	case "savePipeline":
+		encoded, _ := job["pipeline"].(string)
+		doc, err := b64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("could not decode pipeline: %s", err)
+		}
+
+		var pipeline map[string]interface{}
+		if err := json.Unmarshal(doc, &pipeline); err != nil {
+			return fmt.Errorf("could not unmarshal pipeline: %s", err)
+		}
+
+		// Front50 keeps the id of the pipeline of the same name
+		id, _ := pipeline["id"].(string)
+		for existingID, p := range g.pipelines {
+			if p["application"] == pipeline["application"] && p["name"] == pipeline["name"] {
+				id = existingID
+			}
+		}
+		if id == "" {
+			id = g.newID()
+		}
+
+		g.savePipeline(id, pipeline, user)
+	case "upsertProject":
+		project, _ := job["project"].(map[string]interface{})
+		name, _ := project["name"].(string)
+		// Front50 updates, and renames, the project of the id
+		if id, ok := project["id"]; ok {
+			for existingName, existing := range g.projects {
+				if existing["id"] == id {
+					delete(g.projects, existingName)
+				}
+			}
+		} else if existing, ok := g.projects[name]; ok {
+			project["id"] = existing["id"]
+		} else {
+			project["id"] = g.newID()
+		}
+		g.projects[name] = project
+	case "deleteProject":
+		// The provider deletes projects by name, Front50 by id
+		project, _ := job["project"].(map[string]interface{})
+		for name, p := range g.projects {
+			if name == project["id"] || p["id"] == project["id"] {
+				delete(g.projects, name)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported job type %q", jobType)
+	}
+
+	return nil
+}
+
+func (c *client) GetApplication(appName string, dest interface{}) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.unlock()
+
+	app, ok := c.gate.applications[appName]
+	if !ok {
+		return api.ErrCodeNoSuchEntityException
+	}
+	return decode(app, dest)
+}
+
+func (c *client) CreateApplication(createAppTask api.CreateApplicationTask) error {
+	return c.runTask(createAppTask)
+}
+
+func (c *client) DeleteApplication(appName string) error {
+	return c.runTask(map[string]interface{}{
+		"application": appName,
+		"job": []interface{}{map[string]interface{}{
+			"type":        "deleteApplication",
+			"application": map[string]interface{}{"name": appName},
+		}},
+	})
+}
+
+func (c *client) CreatePipeline(createPipeLineTask api.CreatePipeLineTask) error {
+	return c.runTask(createPipeLineTask)
+}
+
+func (g *Gate) savePipeline(id string, pipeline map[string]interface{}, user string) {
+	pipeline["id"] = id
+	pipeline["updateTs"] = strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10)
+	if user != "" {
+		pipeline["lastModifiedBy"] = user
+	}
+	g.pipelines[id] = pipeline
+}
+
+func (c *client) GetPipeline(applicationName, pipelineName string, dest interface{}) (map[string]interface{}, error) {
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+	defer c.unlock()
+
+	for _, p := range c.gate.pipelines {
+		if p["application"] == applicationName && p["name"] == pipelineName {
+			pipeline := clone(p)
+			return pipeline, decode(pipeline, dest)
+		}
+	}
+	return nil, api.ErrCodeNoSuchEntityException
+}
+
+func (c *client) GetPipelineByID(pipelineID string, dest interface{}) (map[string]interface{}, error) {
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+	defer c.unlock()
+
+	p, ok := c.gate.pipelines[pipelineID]
+	if !ok {
+		return nil, api.ErrCodeNoSuchEntityException
+	}
+	pipeline := clone(p)
+	return pipeline, decode(pipeline, dest)
+}
+
+func (c *client) GetPipelines(applicationName string) ([]map[string]interface{}, error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.gate.Pipelines(applicationName), nil
+}
+
+func (c *client) UpdatePipeline(pipelineID string, pipeline interface{}) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.unlock()
+
+	if _, ok := c.gate.pipelines[pipelineID]; !ok {
+		return fmt.Errorf("pipeline %s not found: %w", pipelineID, api.ErrCodeNoSuchEntityException)
+	}
+	c.gate.savePipeline(pipelineID, clone(pipeline), c.user)
+	return nil
+}
+
+func (c *client) DeletePipeline(applicationName, pipelineName string) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.unlock()
+
+	for id, p := range c.gate.pipelines {
+		if p["application"] == applicationName && p["name"] == pipelineName {
+			delete(c.gate.pipelines, id)
+		}
+	}
+	return nil
+}
+
+func (c *client) CreatePipelineTemplate(template interface{}) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.unlock()
+
+	t := clone(template)
+	id, _ := t["id"].(string)
+	if _, ok := c.gate.templates[id]; ok {
+		return fmt.Errorf("pipeline template %s already exists: %w", id, errConflict)
+	}
+	c.gate.templates[id] = t
+	return nil
+}
+
+func (c *client) GetPipelineTemplate(templateID string, dest interface{}) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.unlock()
+
+	t, ok := c.gate.templates[templateID]
+	if !ok {
+		return api.ErrCodeNoSuchEntityException
+	}
+	return decode(clone(t), dest)
+}
+
+func (c *client) UpdatePipelineTemplate(templateID string, template interface{}) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.unlock()
+
+	if _, ok := c.gate.templates[templateID]; !ok {
+		return fmt.Errorf("pipeline template %s not found: %w", templateID, api.ErrCodeNoSuchEntityException)
+	}
+	c.gate.templates[templateID] = clone(template)
+	return nil
+}
+
+func (c *client) DeletePipelineTemplate(templateID string) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.unlock()
+
+	delete(c.gate.templates, templateID)
+	return nil
+}
+
+func (c *client) GetProject(projectName string, dest interface{}) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.unlock()
+
+	p, ok := c.gate.projects[projectName]
+	if !ok {
+		return fmt.Errorf("Project '%s' not found: %w", projectName, api.ErrCodeNoSuchEntityException)
+	}
+	return decode(clone(p), dest)
+}
+
+func (c *client) GetProjects() ([]map[string]interface{}, error) {
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+	defer c.unlock()
+
+	projects := []map[string]interface{}{}
+	for _, p := range c.gate.projects {
+		projects = append(projects, clone(p))
+	}
+	sort.Slice(projects, func(i, j int) bool {
+		return fmt.Sprint(projects[i]["name"]) < fmt.Sprint(projects[j]["name"])
+	})
+	return projects, nil
+}
+
+func (c *client) CreateProject(upsertProjectTask api.UpsertApplicationTask) error {
+	return c.runTask(upsertProjectTask)
+}
+
+func (c *client) DeleteProject(id string, projectName string) error {
+	return c.runTask(map[string]interface{}{
+		"project": projectName,
+		"job": []interface{}{map[string]interface{}{
+			"type":    "deleteProject",
+			"project": map[string]interface{}{"id": id},
+		}},
+	})
+}
+
+func (c *client) CreateCanaryConfig(config api.CanaryConfig) (string, error) {
+	if err := c.lock(); err != nil {
+		return "", err
+	}
+	defer c.unlock()
+
+	id := c.gate.newID()
+	cfg := clone(config)
+	cfg["id"] = id
+	c.gate.canaryConfigs[id] = cfg
+	return id, nil
+}
+
+func (c *client) GetCanaryConfig(id string, dest interface{}) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.unlock()
+
+	cfg, ok := c.gate.canaryConfigs[id]
+	if !ok {
+		return fmt.Errorf("canary config %s not found: %w", id, api.ErrCodeNoSuchEntityException)
+	}
+	return decode(clone(cfg), dest)
+}
+
+func (c *client) UpdateCanaryConfig(id string, config api.CanaryConfig) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.unlock()
+
+	if _, ok := c.gate.canaryConfigs[id]; !ok {
+		return fmt.Errorf("canary config %s not found: %w", id, api.ErrCodeNoSuchEntityException)
+	}
+	cfg := clone(config)
+	cfg["id"] = id
+	c.gate.canaryConfigs[id] = cfg
+	return nil
+}
+
+func (c *client) DeleteCanaryConfig(id string) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.unlock()
+
+	delete(c.gate.canaryConfigs, id)
+	return nil
+}
+
+// InitiateCanary runs the canary analysis right away, every metric of the
+// canary config is classified Pass and the analysis scores CanaryScore
+func (c *client) InitiateCanary(canaryConfigID string, opts api.CanaryExecutionOptions, request api.CanaryExecutionRequest) (string, error) {
+	if err := c.lock(); err != nil {
+		return "", err
+	}
+	defer c.unlock()
+
+	cfg, ok := c.gate.canaryConfigs[canaryConfigID]
+	if !ok {
+		return "", fmt.Errorf("canary config %s not found: %w", canaryConfigID, api.ErrCodeNoSuchEntityException)
+	}
+
+	req := clone(request)
+	thresholds, _ := req["thresholds"].(map[string]interface{})
+	marginal, _ := thresholds["marginal"].(float64)
+	pass, _ := thresholds["pass"].(float64)
+
+	classification := "Pass"
+	switch score := c.gate.CanaryScore; {
+	case score < marginal:
+		classification = "Fail"
+	case score < pass:
+		classification = "Marginal"
+	}
+
+	var results []interface{}
+	metrics, _ := cfg["metrics"].([]interface{})
+	for _, m := range metrics {
+		metric, _ := m.(map[string]interface{})
+		results = append(results, map[string]interface{}{
+			"name":           metric["name"],
+			"classification": "Pass",
+		})
+	}
+
+	id := c.gate.newID()
+	c.gate.canaryExecutions[id] = map[string]interface{}{
+		"complete": true,
+		"status":   "succeeded",
+		"result": map[string]interface{}{
+			"judgeResult": map[string]interface{}{
+				"score": map[string]interface{}{
+					"score":          c.gate.CanaryScore,
+					"classification": classification,
+				},
+				"results": results,
+			},
+		},
+	}
+	return id, nil
+}
+
+func (c *client) GetCanaryResult(canaryExecutionID, storageAccountName string, dest interface{}) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.unlock()
+
+	execution, ok := c.gate.canaryExecutions[canaryExecutionID]
+	if !ok {
+		return api.ErrCodeNoSuchEntityException
+	}
+	return decode(clone(execution), dest)
+}
+
+// clone returns a copy of v as the JSON Gate would have sent, so neither
+// side can change the state of the other
+func clone(v interface{}) map[string]interface{} {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func decode(v map[string]interface{}, dest interface{}) error {
+	return mapstructure.Decode(v, dest)
+}
@@ -0,0 +1,77 @@
+package fakegate
+
+import (
+	"context"
+	b64 "encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/himanhsugusain/terraform-provider-spinnaker/spinnaker/api"
+)
+
+func savePipelineTask(pipeline string) api.CreatePipeLineTask {
+	return api.CreatePipeLineTask{
+		"application": "app",
+		"job": []map[string]interface{}{
+			{"type": "savePipeline", "pipeline": b64.StdEncoding.EncodeToString([]byte(pipeline))},
+		},
+	}
+}
+
+func TestSavePipelineKeepsID(t *testing.T) {
+	gate := New()
+	client := gate.Client().WithRunAsUser("team-svc")
+
+	if err := client.CreatePipeline(savePipelineTask(`{"application": "app", "name": "deploy", "stages": []}`)); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	first, err := client.GetPipeline("app", "deploy", &struct{}{})
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	if err := client.CreatePipeline(savePipelineTask(`{"application": "app", "name": "deploy", "limitConcurrent": true}`)); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	second, err := client.GetPipeline("app", "deploy", &struct{}{})
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	if first["id"] != second["id"] || second["limitConcurrent"] != true {
+		t.Fatalf("expected the pipeline saved in place, got %v then %v", first, second)
+	}
+	if tasks := gate.Tasks(); len(tasks) != 2 || tasks[0].User != "team-svc" || tasks[0].Type != "savePipeline" {
+		t.Fatalf("expected two savePipeline tasks as team-svc, got %v", tasks)
+	}
+}
+
+func TestFailTasks(t *testing.T) {
+	gate := New()
+	gate.FailTasks("createApplication", "Access denied to application")
+
+	err := gate.Client().CreateApplication(api.CreateApplicationTask{
+		"application": "app",
+		"job":         []interface{}{map[string]interface{}{"type": "createApplication", "application": map[string]interface{}{"name": "app"}}},
+	})
+	var taskErr *api.TaskError
+	if !errors.As(err, &taskErr) || taskErr.Message != "Access denied to application" {
+		t.Fatalf("expected a task error, got %v", err)
+	}
+	if err := gate.Client().GetApplication("app", &struct{}{}); !errors.Is(err, api.ErrCodeNoSuchEntityException) {
+		t.Fatalf("expected no application, got %v", err)
+	}
+	if tasks := gate.Tasks(); len(tasks) != 1 || tasks[0].Status != "TERMINAL" {
+		t.Fatalf("expected a terminal task, got %v", tasks)
+	}
+}
+
+func TestWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := New().Client().WithContext(ctx).DeletePipeline("app", "deploy")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the request to stop with the context, got %v", err)
+	}
+}
@@ -0,0 +1,273 @@
+package fakegate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/himanhsugusain/terraform-provider-spinnaker/spinnaker/api"
+)
+
+// Handler returns the REST endpoints of Gate the provider uses, served from
+// the state of the Gate. The Orca tasks complete right away, and the requests
+// are made as the user of the X-SPINNAKER-USER header.
+func (g *Gate) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /version", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"version": "fakegate"})
+	})
+
+	mux.HandleFunc("GET /applications/{application}", func(w http.ResponseWriter, r *http.Request) {
+		app := map[string]interface{}{}
+		err := g.requestClient(r).GetApplication(r.PathValue("application"), &app)
+		writeResult(w, http.StatusOK, app, err)
+	})
+	mux.HandleFunc("GET /applications/{application}/pipelineConfigs", func(w http.ResponseWriter, r *http.Request) {
+		pipelines, err := g.requestClient(r).GetPipelines(r.PathValue("application"))
+		if pipelines == nil {
+			pipelines = []map[string]interface{}{}
+		}
+		writeResult(w, http.StatusOK, pipelines, err)
+	})
+	mux.HandleFunc("GET /applications/{application}/pipelineConfigs/{pipelineName}", func(w http.ResponseWriter, r *http.Request) {
+		pipeline, err := g.requestClient(r).GetPipeline(r.PathValue("application"), r.PathValue("pipelineName"), &map[string]interface{}{})
+		writeResult(w, http.StatusOK, pipeline, err)
+	})
+
+	// The fake Gate keeps no history, the latest revision is the pipeline
+	mux.HandleFunc("GET /pipelineConfigs/{id}/history", func(w http.ResponseWriter, r *http.Request) {
+		pipeline, err := g.requestClient(r).GetPipelineByID(r.PathValue("id"), &map[string]interface{}{})
+		writeResult(w, http.StatusOK, []interface{}{pipeline}, err)
+	})
+	mux.HandleFunc("PUT /pipelines/{id}", func(w http.ResponseWriter, r *http.Request) {
+		pipeline, ok := readJSON(w, r)
+		if !ok {
+			return
+		}
+		err := g.requestClient(r).UpdatePipeline(r.PathValue("id"), pipeline)
+		writeResult(w, http.StatusOK, pipeline, err)
+	})
+	mux.HandleFunc("DELETE /pipelines/{application}/{pipelineName}", func(w http.ResponseWriter, r *http.Request) {
+		err := g.requestClient(r).DeletePipeline(r.PathValue("application"), r.PathValue("pipelineName"))
+		writeResult(w, http.StatusOK, nil, err)
+	})
+
+	mux.HandleFunc("GET /pipelineTemplates", func(w http.ResponseWriter, r *http.Request) {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+
+		templates := []interface{}{}
+		for _, t := range g.templates {
+			templates = append(templates, clone(t))
+		}
+		writeJSON(w, http.StatusOK, templates)
+	})
+	mux.HandleFunc("POST /pipelineTemplates", func(w http.ResponseWriter, r *http.Request) {
+		template, ok := readJSON(w, r)
+		if !ok {
+			return
+		}
+		err := g.requestClient(r).CreatePipelineTemplate(template)
+		writeResult(w, http.StatusAccepted, map[string]interface{}{}, err)
+	})
+	mux.HandleFunc("GET /pipelineTemplates/{id}", func(w http.ResponseWriter, r *http.Request) {
+		template := map[string]interface{}{}
+		err := g.requestClient(r).GetPipelineTemplate(r.PathValue("id"), &template)
+		writeResult(w, http.StatusOK, template, err)
+	})
+	mux.HandleFunc("POST /pipelineTemplates/{id}", func(w http.ResponseWriter, r *http.Request) {
+		template, ok := readJSON(w, r)
+		if !ok {
+			return
+		}
+		err := g.requestClient(r).UpdatePipelineTemplate(r.PathValue("id"), template)
+		writeResult(w, http.StatusAccepted, map[string]interface{}{}, err)
+	})
+	mux.HandleFunc("DELETE /pipelineTemplates/{id}", func(w http.ResponseWriter, r *http.Request) {
+		err := g.requestClient(r).DeletePipelineTemplate(r.PathValue("id"))
+		writeResult(w, http.StatusAccepted, map[string]interface{}{}, err)
+	})
+
+	mux.HandleFunc("GET /projects", func(w http.ResponseWriter, r *http.Request) {
+		projects, err := g.requestClient(r).GetProjects()
+		writeResult(w, http.StatusOK, projects, err)
+	})
+	mux.HandleFunc("GET /projects/{id}", func(w http.ResponseWriter, r *http.Request) {
+		project := map[string]interface{}{}
+		err := g.requestClient(r).GetProject(r.PathValue("id"), &project)
+		writeResult(w, http.StatusOK, project, err)
+	})
+
+	mux.HandleFunc("POST /tasks", func(w http.ResponseWriter, r *http.Request) {
+		task, ok := readJSON(w, r)
+		if !ok {
+			return
+		}
+
+		g.mu.Lock()
+		t, err := g.submitTask(task, r.Header.Get(api.RunAsUserHeader))
+		g.mu.Unlock()
+
+		writeResult(w, http.StatusOK, map[string]interface{}{"ref": "/tasks/" + t.ID}, err)
+	})
+	mux.HandleFunc("GET /tasks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		t, ok := g.task(r.PathValue("id"))
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Errorf("task %s not found", r.PathValue("id")))
+			return
+		}
+		writeJSON(w, http.StatusOK, orcaTask(t))
+	})
+
+	mux.HandleFunc("GET /v2/canaryConfig", func(w http.ResponseWriter, r *http.Request) {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+
+		application := r.URL.Query().Get("application")
+		configs := []interface{}{}
+		for _, c := range g.canaryConfigs {
+			config := clone(c)
+			applications, _ := config["applications"].([]interface{})
+			if application == "" || containsValue(applications, application) {
+				configs = append(configs, config)
+			}
+		}
+		writeJSON(w, http.StatusOK, configs)
+	})
+	mux.HandleFunc("POST /v2/canaryConfig", func(w http.ResponseWriter, r *http.Request) {
+		config, ok := readJSON(w, r)
+		if !ok {
+			return
+		}
+		id, err := g.requestClient(r).CreateCanaryConfig(config)
+		writeResult(w, http.StatusOK, map[string]interface{}{"canaryConfigId": id}, err)
+	})
+	mux.HandleFunc("GET /v2/canaryConfig/{id}", func(w http.ResponseWriter, r *http.Request) {
+		config := map[string]interface{}{}
+		err := g.requestClient(r).GetCanaryConfig(r.PathValue("id"), &config)
+		writeResult(w, http.StatusOK, config, err)
+	})
+	mux.HandleFunc("PUT /v2/canaryConfig/{id}", func(w http.ResponseWriter, r *http.Request) {
+		config, ok := readJSON(w, r)
+		if !ok {
+			return
+		}
+		err := g.requestClient(r).UpdateCanaryConfig(r.PathValue("id"), config)
+		writeResult(w, http.StatusOK, map[string]interface{}{"canaryConfigId": r.PathValue("id")}, err)
+	})
+	mux.HandleFunc("DELETE /v2/canaryConfig/{id}", func(w http.ResponseWriter, r *http.Request) {
+		err := g.requestClient(r).DeleteCanaryConfig(r.PathValue("id"))
+		writeResult(w, http.StatusOK, nil, err)
+	})
+
+	mux.HandleFunc("POST /v2/canaries/canary/{canaryConfigId}", func(w http.ResponseWriter, r *http.Request) {
+		request, ok := readJSON(w, r)
+		if !ok {
+			return
+		}
+		opts := api.CanaryExecutionOptions{
+			Application:        r.URL.Query().Get("application"),
+			MetricsAccountName: r.URL.Query().Get("metricsAccountName"),
+			StorageAccountName: r.URL.Query().Get("storageAccountName"),
+		}
+		id, err := g.requestClient(r).InitiateCanary(r.PathValue("canaryConfigId"), opts, request)
+		writeResult(w, http.StatusOK, map[string]interface{}{"canaryExecutionId": id}, err)
+	})
+	mux.HandleFunc("GET /v2/canaries/canary/{canaryExecutionId}", func(w http.ResponseWriter, r *http.Request) {
+		execution := map[string]interface{}{}
+		err := g.requestClient(r).GetCanaryResult(r.PathValue("canaryExecutionId"), r.URL.Query().Get("storageAccountName"), &execution)
+		writeResult(w, http.StatusOK, execution, err)
+	})
+
+	return mux
+}
+
+// requestClient returns the client of the Gate making the request as its user
+func (g *Gate) requestClient(r *http.Request) api.Client {
+	return g.Client().WithRunAsUser(r.Header.Get(api.RunAsUserHeader)).WithContext(r.Context())
+}
+
+func (g *Gate) task(id string) (Task, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, t := range g.tasks {
+		if t.ID == id {
+			return t, true
+		}
+	}
+	return Task{}, false
+}
+
+// orcaTask returns the task as Orca reports it, with the failed step and the
+// exception where api.WaitForSuccessfulTask looks for them
+func orcaTask(t Task) map[string]interface{} {
+	task := map[string]interface{}{
+		"id":          t.ID,
+		"application": t.Application,
+		"status":      t.Status,
+		"steps":       []interface{}{map[string]interface{}{"name": t.Type, "status": t.Status}},
+	}
+	if t.Message != "" {
+		task["variables"] = []interface{}{map[string]interface{}{
+			"key":   "exception",
+			"value": map[string]interface{}{"details": map[string]interface{}{"error": t.Message}},
+		}}
+	}
+	return task
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request) (map[string]interface{}, bool) {
+	var body map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("could not decode the request body: %s", err))
+		return nil, false
+	}
+	return body, true
+}
+
+// writeResult writes v with status, or the error with the status Gate
+// answers it with
+func writeResult(w http.ResponseWriter, status int, v interface{}, err error) {
+	switch {
+	case err == nil:
+		writeJSON(w, status, v)
+	case errors.Is(err, api.ErrCodeNoSuchEntityException):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, errConflict):
+		writeError(w, http.StatusConflict, err)
+	default:
+		writeError(w, http.StatusBadRequest, err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]interface{}{
+		"status":  status,
+		"error":   http.StatusText(status),
+		"message": err.Error(),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if v == nil {
+		return
+	}
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("[ERROR] could not write the response: %s", err)
+	}
+}
+
+func containsValue(values []interface{}, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
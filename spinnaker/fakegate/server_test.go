@@ -0,0 +1,210 @@
+package fakegate
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/himanhsugusain/terraform-provider-spinnaker/spinnaker/api"
+)
+
+// newTestServerClient returns a client of the provider talking to the Gate
+// through its HTTP server
+func newTestServerClient(t *testing.T, gate *Gate) api.Client {
+	server := httptest.NewServer(gate.Handler())
+	t.Cleanup(server.Close)
+
+	client, err := api.NewGateClient(api.ClientConfig{
+		GateEndpoint: server.URL,
+		ConfigPath:   filepath.Join(t.TempDir(), "config"),
+	})
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	return api.NewClient(client)
+}
+
+func TestServerApplication(t *testing.T) {
+	gate := New()
+	client := newTestServerClient(t, gate).WithRunAsUser("team-svc")
+
+	err := client.CreateApplication(api.CreateApplicationTask{
+		"application": "app",
+		"job":         []interface{}{map[string]interface{}{"type": "createApplication", "application": map[string]interface{}{"name": "app", "email": "acceptance@test.com"}}},
+	})
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	app := struct {
+		Name       string
+		Attributes map[string]interface{}
+	}{}
+	if err := client.GetApplication("app", &app); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	if app.Name != "app" || app.Attributes["email"] != "acceptance@test.com" {
+		t.Fatalf("unexpected application: %v", app)
+	}
+	if tasks := gate.Tasks(); len(tasks) != 1 || tasks[0].User != "team-svc" {
+		t.Fatalf("expected the task to run as team-svc, got %v", tasks)
+	}
+
+	if err := client.DeleteApplication("app"); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	if err := client.GetApplication("app", &app); !errors.Is(err, api.ErrCodeNoSuchEntityException) {
+		t.Fatalf("expected the application to be deleted, got %v", err)
+	}
+}
+
+func TestServerTaskFailure(t *testing.T) {
+	gate := New()
+	gate.FailTasks("upsertProject", "Project already exists")
+	client := newTestServerClient(t, gate)
+
+	err := client.CreateProject(api.UpsertApplicationTask{
+		"application": "spinnaker",
+		"job":         []interface{}{map[string]interface{}{"type": "upsertProject", "project": map[string]interface{}{"name": "proj"}}},
+	})
+	var taskErr *api.TaskError
+	if !errors.As(err, &taskErr) {
+		t.Fatalf("expected a task error, got %v", err)
+	}
+	if taskErr.Step != "upsertProject" || taskErr.Message != "Project already exists" {
+		t.Fatalf("expected the failed step and exception, got %+v", taskErr)
+	}
+}
+
+func TestServerPipeline(t *testing.T) {
+	gate := New()
+	// The cache of the provider must see the writes through the server
+	client := api.NewCachedClient(newTestServerClient(t, gate))
+
+	if err := client.CreatePipeline(savePipelineTask(`{"application": "app", "name": "deploy", "stages": []}`)); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	if pipelines, err := client.GetPipelines("app"); err != nil || len(pipelines) != 1 || pipelines[0]["name"] != "deploy" {
+		t.Fatalf("expected the pipeline in the list, got %v: %v", pipelines, err)
+	}
+
+	var p struct{ ID string }
+	pipeline, err := client.GetPipeline("app", "deploy", &p)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	pipeline["limitConcurrent"] = true
+	if err := client.UpdatePipeline(p.ID, pipeline); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	if pipeline, err = client.GetPipeline("app", "deploy", &p); err != nil || pipeline["limitConcurrent"] != true {
+		t.Fatalf("expected the pipeline updated, got %v: %v", pipeline, err)
+	}
+	if pipeline, err = client.GetPipelineByID(p.ID, &p); err != nil || pipeline["name"] != "deploy" {
+		t.Fatalf("expected the pipeline read by its id, got %v: %v", pipeline, err)
+	}
+
+	if err := client.DeletePipeline("app", "deploy"); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	if _, err := client.GetPipeline("app", "deploy", &p); !errors.Is(err, api.ErrCodeNoSuchEntityException) {
+		t.Fatalf("expected the pipeline to be deleted, got %v", err)
+	}
+}
+
+func TestServerPipelineTemplate(t *testing.T) {
+	client := newTestServerClient(t, New())
+
+	if err := client.CreatePipelineTemplate(map[string]interface{}{"id": "tmpl", "schema": "v2"}); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	if err := client.UpdatePipelineTemplate("tmpl", map[string]interface{}{"id": "tmpl", "schema": "v2", "variables": []interface{}{}}); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	template := map[string]interface{}{}
+	if err := client.GetPipelineTemplate("tmpl", &template); err != nil || template["variables"] == nil {
+		t.Fatalf("expected the template updated, got %v: %v", template, err)
+	}
+
+	if err := client.DeletePipelineTemplate("tmpl"); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	if err := client.GetPipelineTemplate("tmpl", &template); !errors.Is(err, api.ErrCodeNoSuchEntityException) {
+		t.Fatalf("expected the template to be deleted, got %v", err)
+	}
+}
+
+func TestServerCanary(t *testing.T) {
+	gate := New()
+	gate.CanaryScore = 80
+	client := newTestServerClient(t, gate)
+
+	id, err := client.CreateCanaryConfig(api.CanaryConfig{"name": "cfg", "metrics": []interface{}{map[string]interface{}{"name": "CPU"}}})
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	if err := client.UpdateCanaryConfig(id, api.CanaryConfig{"name": "cfg", "description": "updated", "metrics": []interface{}{map[string]interface{}{"name": "CPU"}}}); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	config := map[string]interface{}{}
+	if err := client.GetCanaryConfig(id, &config); err != nil || config["description"] != "updated" {
+		t.Fatalf("expected the canary config updated, got %v: %v", config, err)
+	}
+
+	executionID, err := client.InitiateCanary(id, api.CanaryExecutionOptions{Application: "app"}, api.CanaryExecutionRequest{
+		"thresholds": map[string]interface{}{"marginal": 75, "pass": 95},
+	})
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	var execution struct {
+		Complete bool
+		Result   struct {
+			JudgeResult struct {
+				Score struct {
+					Score          float64
+					Classification string
+				}
+			}
+		}
+	}
+	if err := client.GetCanaryResult(executionID, "", &execution); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	if score := execution.Result.JudgeResult.Score; !execution.Complete || score.Score != 80 || score.Classification != "Marginal" {
+		t.Fatalf("unexpected canary execution: %+v", execution)
+	}
+
+	if err := client.DeleteCanaryConfig(id); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	if err := client.GetCanaryConfig(id, &config); !errors.Is(err, api.ErrCodeNoSuchEntityException) {
+		t.Fatalf("expected the canary config to be deleted, got %v", err)
+	}
+}
+
+func TestServerCapabilities(t *testing.T) {
+	server := httptest.NewServer(New().Handler())
+	t.Cleanup(server.Close)
+
+	client, version, err := api.NewGateClientVersion(api.ClientConfig{
+		GateEndpoint: server.URL,
+		ConfigPath:   filepath.Join(t.TempDir(), "config"),
+	})
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	capabilities := api.DetectCapabilities(client, version)
+	for _, capability := range []api.Capability{api.CapabilityPipelineTemplates, api.CapabilityCanary} {
+		if err := capabilities.Require(context.Background(), capability); err != nil {
+			t.Fatalf("failed: %v", err)
+		}
+	}
+}
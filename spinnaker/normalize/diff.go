@@ -0,0 +1,131 @@
+package normalize
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// Change is a value added, removed or changed between two JSON documents
+type Change struct {
+	// Path is the path of the value, in the syntax of ParsePath
+	Path string
+	// Old is the value before, nil when it is added
+	Old interface{}
+	// New is the value after, nil when it is removed
+	New interface{}
+	// Added and Removed tell added and removed values from null ones
+	Added, Removed bool
+}
+
+// String returns the change as stages[3].timeout: 300 -> 600
+func (c Change) String() string {
+	switch {
+	case c.Added:
+		return fmt.Sprintf("%s: added %s", c.Path, encodeValue(c.New))
+	case c.Removed:
+		return fmt.Sprintf("%s: removed %s", c.Path, encodeValue(c.Old))
+	default:
+		return fmt.Sprintf("%s: %s -> %s", c.Path, encodeValue(c.Old), encodeValue(c.New))
+	}
+}
+
+// Diff returns the changes from the JSON document old to new, ordered by
+// path. Objects are compared key by key and arrays element by element, so a
+// stage inserted in the middle of the stages changes all the ones after it.
+func Diff(old, new string) ([]Change, error) {
+	var o, n interface{}
+	if err := json.Unmarshal([]byte(old), &o); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(new), &n); err != nil {
+		return nil, err
+	}
+
+	return diffValues("", o, n, nil), nil
+}
+
+func diffValues(path string, old, new interface{}, changes []Change) []Change {
+	switch o := old.(type) {
+	case map[string]interface{}:
+		n, ok := new.(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		keys := make([]string, 0, len(o)+len(n))
+		for k := range o {
+			keys = append(keys, k)
+		}
+		for k := range n {
+			if _, ok := o[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			ov, inOld := o[k]
+			nv, inNew := n[k]
+			p := keyPath(path, k)
+			switch {
+			case !inOld:
+				changes = append(changes, Change{Path: p, New: nv, Added: true})
+			case !inNew:
+				changes = append(changes, Change{Path: p, Old: ov, Removed: true})
+			default:
+				changes = diffValues(p, ov, nv, changes)
+			}
+		}
+		return changes
+	case []interface{}:
+		n, ok := new.([]interface{})
+		if !ok {
+			break
+		}
+
+		for i := 0; i < len(o) || i < len(n); i++ {
+			p := path + "[" + strconv.Itoa(i) + "]"
+			switch {
+			case i >= len(o):
+				changes = append(changes, Change{Path: p, New: n[i], Added: true})
+			case i >= len(n):
+				changes = append(changes, Change{Path: p, Old: o[i], Removed: true})
+			default:
+				changes = diffValues(p, o[i], n[i], changes)
+			}
+		}
+		return changes
+	}
+
+	if encodeValue(old) != encodeValue(new) {
+		if path == "" {
+			path = "$"
+		}
+		changes = append(changes, Change{Path: path, Old: old, New: new})
+	}
+	return changes
+}
+
+var identifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*$`)
+
+// keyPath returns the path of key k of the object at path
+func keyPath(path, k string) string {
+	if !identifierRegexp.MatchString(k) {
+		return path + "['" + k + "']"
+	}
+	if path == "" {
+		return k
+	}
+	return path + "." + k
+}
+
+func encodeValue(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
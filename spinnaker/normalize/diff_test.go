@@ -0,0 +1,76 @@
+package normalize
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tcs := map[string]struct {
+		old      string
+		new      string
+		expected []string
+	}{
+		"equal": {
+			old: `{"stages": [{"timeout": 300}], "keepWaitingPipelines": false}`,
+			new: `{"keepWaitingPipelines": false, "stages": [{"timeout": 300}]}`,
+		},
+		"changed": {
+			old:      `{"stages": [{"refId": "1"}, {"refId": "2", "timeout": 300}]}`,
+			new:      `{"stages": [{"refId": "1"}, {"refId": "2", "timeout": 600}]}`,
+			expected: []string{"stages[1].timeout: 300 -> 600"},
+		},
+		"added and removed": {
+			old:      `{"limitConcurrent": true, "stages": [{"type": "wait"}]}`,
+			new:      `{"stages": [{"type": "wait"}, {"type": "manualJudgment"}], "triggers": []}`,
+			expected: []string{`limitConcurrent: removed true`, `stages[1]: added {"type":"manualJudgment"}`, `triggers: added []`},
+		},
+		"type changed": {
+			old:      `{"parameterConfig": [], "notifications": null}`,
+			new:      `{"parameterConfig": {}, "notifications": [{"type": "slack"}]}`,
+			expected: []string{`notifications: null -> [{"type":"slack"}]`, `parameterConfig: [] -> {}`},
+		},
+		"quoted keys": {
+			old:      `{"tags": {"team.name": "a", "owner": "b"}}`,
+			new:      `{"tags": {"team.name": "c", "owner": "b"}}`,
+			expected: []string{`tags['team.name']: "a" -> "c"`},
+		},
+		"document": {
+			old:      `[]`,
+			new:      `{}`,
+			expected: []string{`$: [] -> {}`},
+		},
+	}
+
+	for name, tc := range tcs {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			changes, err := Diff(tc.old, tc.new)
+			if err != nil {
+				t.Fatalf("failed: %v", err)
+			}
+
+			var got []string
+			for _, c := range changes {
+				got = append(got, c.String())
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Fatalf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestDiffPathsParse(t *testing.T) {
+	changes, err := Diff(`{"tags": {"team.name": "a"}, "stages": [{"refId": "1"}]}`, `{"tags": {"team.name": "b"}, "stages": [{"refId": "2"}]}`)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	for _, c := range changes {
+		if _, err := ParsePath(c.Path); err != nil {
+			t.Fatalf("expected the path of %s to be usable in the rules, got %v", c, err)
+		}
+	}
+}
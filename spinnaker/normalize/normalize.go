@@ -0,0 +1,206 @@
+// Package normalize removes the keys Spinnaker, or the attributes of a
+// resource, manage from JSON documents, so that the documents read from
+// Spinnaker compare equal to the ones of the configuration.
+//
+// The keys are given as paths, a subset of JSONPath which is also valid
+// JMESPath:
+//
+//	updateTs            the updateTs key of the document, $.updateTs as well
+//	triggers[*].id      the id of every trigger
+//	stages[0].refId     the refId of the first stage
+//	*.lastModifiedBy    the lastModifiedBy of every value of the document
+//	['key.with.dots']   a key which is not an identifier
+//
+// A path ends with a key, whole array elements can not be removed.
+package normalize
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// segment is a step of a path, a key of an object, an index of an array or
+// every value of either
+type segment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// Path is a path to the keys removed from documents
+type Path struct {
+	raw      string
+	segments []segment
+}
+
+// ParsePath parses a path such as stages[*].refId
+func ParsePath(s string) (Path, error) {
+	p := Path{raw: s}
+	rest := strings.TrimSpace(s)
+	rest = strings.TrimPrefix(rest, "$")
+	if rest == "" {
+		return p, fmt.Errorf("path %q is empty", s)
+	}
+
+	for i := 0; rest != ""; i++ {
+		switch {
+		case rest[0] == '.' || i == 0 && rest[0] != '[':
+			rest = strings.TrimPrefix(rest, ".")
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			key := rest[:end]
+			if key == "" {
+				return p, fmt.Errorf("path %q has an empty key", s)
+			}
+			if key == "*" {
+				p.segments = append(p.segments, segment{wildcard: true})
+			} else {
+				p.segments = append(p.segments, segment{key: key})
+			}
+			rest = rest[end:]
+		case rest[0] == '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return p, fmt.Errorf("path %q has an unclosed [", s)
+			}
+			seg, err := parseBracket(rest[1:end])
+			if err != nil {
+				return p, fmt.Errorf("path %q: %s", s, err)
+			}
+			p.segments = append(p.segments, seg)
+			rest = rest[end+1:]
+		default:
+			return p, fmt.Errorf("path %q: unexpected %q", s, rest[0])
+		}
+	}
+
+	if last := p.segments[len(p.segments)-1]; last.isIndex || last.wildcard {
+		return p, fmt.Errorf("path %q must end with a key", s)
+	}
+	return p, nil
+}
+
+// parseBracket parses the inside of [*], [0] or ['key']
+func parseBracket(s string) (segment, error) {
+	if s == "*" {
+		return segment{wildcard: true}, nil
+	}
+	if len(s) >= 2 && (s[0] == '\'' && s[len(s)-1] == '\'' || s[0] == '"' && s[len(s)-1] == '"') {
+		return segment{key: s[1 : len(s)-1]}, nil
+	}
+
+	index, err := strconv.Atoi(s)
+	if err != nil || index < 0 {
+		return segment{}, fmt.Errorf("[%s] is neither an index, * nor a quoted key", s)
+	}
+	return segment{index: index, isIndex: true}, nil
+}
+
+func (p Path) String() string {
+	return p.raw
+}
+
+// Delete removes the keys of the path from doc, a decoded JSON document
+func (p Path) Delete(doc interface{}) {
+	deletePath(doc, p.segments)
+}
+
+func deletePath(v interface{}, segments []segment) {
+	seg, rest := segments[0], segments[1:]
+	if len(rest) == 0 {
+		if m, ok := v.(map[string]interface{}); ok {
+			delete(m, seg.key)
+		}
+		return
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if seg.wildcard {
+			for _, child := range v {
+				deletePath(child, rest)
+			}
+		} else if child, ok := v[seg.key]; ok && !seg.isIndex {
+			deletePath(child, rest)
+		}
+	case []interface{}:
+		if seg.wildcard {
+			for _, child := range v {
+				deletePath(child, rest)
+			}
+		} else if seg.isIndex && seg.index < len(v) {
+			deletePath(v[seg.index], rest)
+		}
+	}
+}
+
+// Rules are the paths removed from the documents of a resource
+type Rules []Path
+
+// NewRules parses the paths of the rules
+func NewRules(paths ...string) (Rules, error) {
+	return Rules(nil).With(paths...)
+}
+
+// MustRules is NewRules for the rules of the provider, it panics on an
+// invalid path
+func MustRules(paths ...string) Rules {
+	r, err := NewRules(paths...)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// With returns the rules with the paths added, such as the ones a user
+// configures on top of the keys managed by Spinnaker
+func (r Rules) With(paths ...string) (Rules, error) {
+	rules := append(Rules(nil), r...)
+	for _, s := range paths {
+		p, err := ParsePath(s)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, p)
+	}
+	return rules, nil
+}
+
+// Apply removes the paths of the rules from doc in place
+func (r Rules) Apply(doc interface{}) {
+	for _, p := range r {
+		p.Delete(doc)
+	}
+}
+
+// Encode returns doc without the paths of the rules, encoded with sorted
+// keys. doc is left as is.
+func (r Rules) Encode(doc interface{}) (string, error) {
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	return r.Normalize(string(b))
+}
+
+// Normalize decodes the JSON document, removes the paths of the rules and
+// encodes it with sorted keys and no whitespace
+func (r Rules) Normalize(doc string) (string, error) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(doc), &v); err != nil {
+		return "", err
+	}
+
+	r.Apply(v)
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,108 @@
+package normalize
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	tcs := map[string]struct {
+		path string
+		err  string
+	}{
+		"key":             {path: "updateTs"},
+		"root":            {path: "$.updateTs"},
+		"wildcard":        {path: "triggers[*].id"},
+		"index":           {path: "stages[0].refId"},
+		"object wildcard": {path: "*.lastModifiedBy"},
+		"quoted key":      {path: "['key.with.dots']"},
+		"empty":           {path: "$", err: "is empty"},
+		"empty key":       {path: "stages..refId", err: "has an empty key"},
+		"unclosed":        {path: "stages[0.refId", err: "has an unclosed ["},
+		"invalid index":   {path: "stages[-1].refId", err: "is neither an index, * nor a quoted key"},
+		"array element":   {path: "stages[*]", err: "must end with a key"},
+	}
+
+	for name, tc := range tcs {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			_, err := ParsePath(tc.path)
+			if tc.err == "" && err != nil {
+				t.Fatalf("failed: %v", err)
+			}
+			if tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+				t.Fatalf("expected %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestRulesNormalize(t *testing.T) {
+	doc := `{
+		"id": "01ABC",
+		"locked": {"ui": true},
+		"stages": [{"name": "deploy", "refId": "1"}, {"name": "verify", "refId": "2"}],
+		"triggers": [{"type": "git", "id": "a"}],
+		"notifications": {"slack": {"lastModifiedBy": "admin", "address": "#deploys"}},
+		"key.with.dots": true
+	}`
+
+	tcs := map[string]struct {
+		paths    []string
+		expected string
+	}{
+		"no rules": {
+			expected: `{"id":"01ABC","key.with.dots":true,"locked":{"ui":true},"notifications":{"slack":{"address":"#deploys","lastModifiedBy":"admin"}},"stages":[{"name":"deploy","refId":"1"},{"name":"verify","refId":"2"}],"triggers":[{"id":"a","type":"git"}]}`,
+		},
+		"keys": {
+			paths:    []string{"id", "$.locked", "['key.with.dots']"},
+			expected: `{"notifications":{"slack":{"address":"#deploys","lastModifiedBy":"admin"}},"stages":[{"name":"deploy","refId":"1"},{"name":"verify","refId":"2"}],"triggers":[{"id":"a","type":"git"}]}`,
+		},
+		"wildcards": {
+			paths:    []string{"id", "locked", "key.with.dots", "stages[*].refId", "triggers[*].id", "notifications.*.lastModifiedBy"},
+			expected: `{"key.with.dots":true,"notifications":{"slack":{"address":"#deploys"}},"stages":[{"name":"deploy"},{"name":"verify"}],"triggers":[{"type":"git"}]}`,
+		},
+		"index": {
+			paths:    []string{"stages[1].refId", "stages[5].refId", "missing.key"},
+			expected: `{"id":"01ABC","key.with.dots":true,"locked":{"ui":true},"notifications":{"slack":{"address":"#deploys","lastModifiedBy":"admin"}},"stages":[{"name":"deploy","refId":"1"},{"name":"verify"}],"triggers":[{"id":"a","type":"git"}]}`,
+		},
+	}
+
+	for name, tc := range tcs {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			rules, err := NewRules(tc.paths...)
+			if err != nil {
+				t.Fatalf("failed: %v", err)
+			}
+
+			got, err := rules.Normalize(doc)
+			if err != nil {
+				t.Fatalf("failed: %v", err)
+			}
+			if got != tc.expected {
+				t.Fatalf("expected %s, got %s", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestRulesEncodeKeepsDocument(t *testing.T) {
+	doc := map[string]interface{}{
+		"id":     "01ABC",
+		"stages": []interface{}{map[string]interface{}{"refId": "1"}},
+	}
+
+	got, err := MustRules("id", "stages[*].refId").Encode(doc)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	if got != `{"stages":[{}]}` {
+		t.Fatalf("unexpected document: %s", got)
+	}
+	if _, ok := doc["id"]; !ok {
+		t.Fatalf("expected the document to be left as is, got %v", doc)
+	}
+}
@@ -1,11 +1,18 @@
 package spinnaker
 
 import (
-	"os"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	api "github.com/himanhsugusain/terraform-provider-spinnaker/spinnaker/api"
 	gate "github.com/spinnaker/spin/cmd/gateclient"
-	"github.com/spinnaker/spin/cmd/output"
 )
 
 func Provider() *schema.Provider {
@@ -14,77 +21,470 @@ func Provider() *schema.Provider {
 			"gate_endpoint": {
 				Type:        schema.TypeString,
 				Optional:    true,
-				Description: "URL for Spinnaker Gate",
-				DefaultFunc: schema.EnvDefaultFunc("GATE_ENDPOINT", nil),
+				Description: "URL for Spinnaker Gate, can be set with SPINNAKER_GATE_ENDPOINT or GATE_ENDPOINT",
 			},
 			"config": {
 				Type:        schema.TypeString,
 				Optional:    true,
-				Description: "Path to Gate config file",
-				DefaultFunc: schema.EnvDefaultFunc("SPINNAKER_CONFIG_PATH", nil),
+				Description: "Path to Gate config file, can be set with SPINNAKER_CONFIG_PATH",
 			},
 			"ignore_cert_errors": {
 				Type:        schema.TypeBool,
 				Optional:    true,
-				Description: "Ignore certificate errors from Gate",
-				Default:     false,
+				Description: "Ignore certificate errors from Gate, prefer ca_bundle for Gates with internal certificates. Can be set with SPINNAKER_IGNORE_CERT_ERRORS",
+			},
+			// client_cert and client_key require each other, which is checked
+			// once the environment is looked up
+			"client_cert": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "PEM encoded x509 client certificate, or the path to it, presented to Gate. Can be set with SPINNAKER_CLIENT_CERT",
+			},
+			"client_key": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				Description: "PEM encoded private key of client_cert, or the path to it. Can be set with SPINNAKER_CLIENT_KEY",
+			},
+			"ca_bundle": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "PEM encoded CA certificates, or the path to them, trusted in addition to the system ones to verify Gate. Can be set with SPINNAKER_CA_BUNDLE",
 			},
 			"default_headers": {
 				Type:        schema.TypeString,
 				Optional:    true,
 				Description: "Headers to be passed to the gate endpoint by the client on each request",
 				Default:     "",
+				Deprecated:  "use headers or headers_from_env instead, default_headers is not sensitive and shows up in plan logs",
+			},
+			"headers": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Sensitive:   true,
+				Description: "Headers sent to Gate on each request, such as an IAP or proxy token. Can be set with SPINNAKER_HEADERS as comma separated name=value pairs",
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"headers_from_env": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Description: "Headers sent to Gate on each request, mapping the header name to the environment variable holding its value. Can be set with SPINNAKER_HEADERS_FROM_ENV as comma separated name=variable pairs",
+				Elem:        &schema.Schema{Type: schema.TypeString},
 			},
 			"retry_timeout": {
 				Type:        schema.TypeInt,
 				Optional:    true,
-				Description: "Maximum time to wait (when polling) for a task to become completed.",
+				Description: "Deprecated, has no effect. Orca tasks are waited for within the timeouts of each resource.",
 				Default:     60,
+				Deprecated:  "retry_timeout has no effect, Orca tasks are waited for within the timeouts of each resource, use a timeouts block instead",
+			},
+			"retry": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "Retry policy of the requests to Gate failing with a dropped connection, 429, 502, 503 or 504. Only reads are retried, and task submissions Gate did not process: refused connections, 429 or 503 with Retry-After. Can be set with SPINNAKER_RETRY_MAX_ATTEMPTS, SPINNAKER_RETRY_BASE_DELAY and SPINNAKER_RETRY_MAX_DELAY",
+				Elem: &schema.Resource{
+					Schema: getProviderRetrySchema(),
+				},
+			},
+			"max_requests_per_second": {
+				Type:         schema.TypeFloat,
+				Optional:     true,
+				Description:  "Maximum rate of the requests to Gate shared by all resources, 0 means unlimited. Can be set with SPINNAKER_MAX_REQUESTS_PER_SECOND",
+				ValidateFunc: validation.FloatAtLeast(0),
+			},
+			"max_concurrent_requests": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Description:  "Maximum number of requests to Gate in flight shared by all resources, 0 means unlimited. Can be set with SPINNAKER_MAX_CONCURRENT_REQUESTS",
+				ValidateFunc: validation.IntAtLeast(0),
 			},
 			"ignore_redirects": {
 				Type:        schema.TypeBool,
 				Optional:    true,
-				Description: "ignore redirects",
-				Default:     false,
+				Description: "ignore redirects, can be set with SPINNAKER_IGNORE_REDIRECTS",
+			},
+			"access_token": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				Sensitive:     true,
+				Description:   "Static bearer token sent to Gate on each request, can be set with SPINNAKER_ACCESS_TOKEN",
+				ConflictsWith: []string{"auth.0.oauth2", "auth.0.basic"},
+			},
+			"run_as_user": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "User, usually a service account, all requests to Gate are made as. The caller must be allowed to impersonate it in Fiat. Can be set with SPINNAKER_RUN_AS_USER",
+			},
+			"default_application": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Description:  "Application of the pipelines and canary configs, and name of the application, which omit it. Can be set with SPINNAKER_DEFAULT_APPLICATION",
+				ValidateFunc: validateSpinnakerApplicationName,
+			},
+			"default_owner_email": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Email of the owner of the applications and projects which omit it. Can be set with SPINNAKER_DEFAULT_OWNER_EMAIL",
+			},
+			"auth": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "Authentication to Gate, used instead of the authentication of the environment and of the spin config file",
+				Elem: &schema.Resource{
+					Schema: getProviderAuthSchema(),
+				},
 			},
 		},
 		ResourcesMap: map[string]*schema.Resource{
 			"spinnaker_application":       resourceSpinnakerApplication(),
+			"spinnaker_canary_analysis":   resourceSpinnakerCanaryAnalysis(),
 			"spinnaker_canary_config":     resourceSpinnakerCanaryConfig(),
 			"spinnaker_pipeline":          resourcePipeline(),
 			"spinnaker_pipeline_template": resourcePipelineTemplate(),
 			"spinnaker_project":           resourceSpinnakerProject(),
 		},
 		DataSourcesMap: map[string]*schema.Resource{
-			"spinnaker_application":   datasourceApplication(),
-			"spinnaker_canary_config": datasourceCanaryConfig(),
-			"spinnaker_pipeline":      datasourcePipeline(),
-			"spinnaker_project":       datasourceProject(),
+			"spinnaker_application":            datasourceApplication(),
+			"spinnaker_canary_config":          datasourceCanaryConfig(),
+			"spinnaker_canary_config_document": datasourceCanaryConfigDocument(),
+			"spinnaker_pipeline":               datasourcePipeline(),
+			"spinnaker_project":                datasourceProject(),
 		},
-		ConfigureFunc: providerConfigureFunc,
+		ConfigureContextFunc: providerConfigureFunc,
 	}
 }
 
 type gateConfig struct {
-	client *gate.GatewayClient
+	client api.Client
+	// capabilities are the version and the features of Gate, nil when they
+	// are not known
+	capabilities *api.Capabilities
+	// defaults are the values of the arguments the resources omit, keyed by
+	// the attribute of the provider, such as default_application
+	defaults map[string]string
 }
 
-func providerConfigureFunc(data *schema.ResourceData) (interface{}, error) {
-	gateEndpoint := data.Get("gate_endpoint").(string)
-	config := data.Get("config").(string)
-	ignoreCertErrors := data.Get("ignore_cert_errors").(bool)
-	defaultHeaders := data.Get("default_headers").(string)
-	ignoreRedirects := data.Get("ignore_redirects").(bool)
-	retryTimeout := data.Get("retry_timeout").(int)
-
-	ui := output.NewUI(false, false, output.MarshalToJson, os.Stdout, os.Stderr)
+func providerConfigureFunc(ctx context.Context, data *schema.ResourceData) (interface{}, diag.Diagnostics) {
+	cfg, r, err := expandProviderClientConfig(ctx, data)
+	if err != nil {
+		return nil, r.Diagnostics(err)
+	}
+	defaults := expandProviderDefaults(r)
+	if err := r.Err(); err != nil {
+		return nil, r.Diagnostics(err)
+	}
+	tflog.Debug(ctx, "Configuring the provider", r.Sources())
 
-	client, err := gate.NewGateClient(ui, gateEndpoint, defaultHeaders, config, ignoreCertErrors, ignoreRedirects, retryTimeout)
+	client, version, err := api.NewGateClientVersion(cfg)
 	if err != nil {
-		return nil, err
+		return nil, r.Diagnostics(err)
 	}
 
+	config := newGateConfig(client, version)
+	config.defaults = defaults
+	return config, nil
+}
+
+// newGateConfig returns the meta of the resources, the capabilities of Gate
+// are probed once for all the resources, and the reads of the resources share
+// a cache for the life of the provider
+func newGateConfig(client *gate.GatewayClient, version string) gateConfig {
 	return gateConfig{
-		client: client,
-	}, nil
+		client:       api.NewCachedClient(api.NewClient(client)),
+		capabilities: api.DetectCapabilities(client, version),
+	}
+}
+
+// require returns an error when Gate does not have the capability a
+// resource needs. It is checked on plan, create and update only, so that a
+// resource left over on a Gate without the capability can still be refreshed
+// and destroyed.
+func (c gateConfig) require(ctx context.Context, capability api.Capability) error {
+	return c.capabilities.Require(ctx, capability)
+}
+
+// customizeDiffRequire fails the plan of a resource whose capability is not
+// enabled on Gate, rather than its apply
+func customizeDiffRequire(capability api.Capability) schema.CustomizeDiffFunc {
+	return func(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+		if meta == nil {
+			return nil
+		}
+		return meta.(gateConfig).require(ctx, capability)
+	}
+}
+
+// customizeDiffDefault plans the default of the provider for key when key,
+// and its deprecated aliases, are omitted from the configuration, so that the
+// diff shows the value which is written. A resource which is created fails
+// without the default.
+func customizeDiffDefault(key, setting string, aliases ...string) schema.CustomizeDiffFunc {
+	return func(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+		for _, k := range append([]string{key}, aliases...) {
+			if !omitted(d, k) {
+				return nil
+			}
+		}
+
+		var value string
+		if meta != nil {
+			value = meta.(gateConfig).defaults[setting]
+		}
+		if value == "" {
+			if d.Id() == "" {
+				return fmt.Errorf("%s is required, set it or the %s of the provider", key, setting)
+			}
+			// Keep the value of the state, such as the one of an import
+			return nil
+		}
+
+		if _, ok := d.Get(key).(*schema.Set); ok {
+			return d.SetNew(key, []interface{}{value})
+		}
+		return d.SetNew(key, value)
+	}
+}
+
+// omitted tells whether key is not set in the configuration. The raw
+// configuration is missing when the diff does not come from Terraform, the
+// value of the state then tells it.
+func omitted(d *schema.ResourceDiff, key string) bool {
+	raw := d.GetRawConfig()
+	if raw.IsNull() || !raw.IsKnown() {
+		_, ok := d.GetOk(key)
+		return !ok
+	}
+	return raw.GetAttr(key).IsNull()
+}
+
+// expandProviderClientConfig returns the settings of the Gate client of the
+// provider configuration and the environment, with where each one comes from.
+// The messages of the spin config authentication flows are logged rather
+// than written to the output of the plugin.
+func expandProviderClientConfig(ctx context.Context, data *schema.ResourceData) (api.ClientConfig, *providerConfigResolver, error) {
+	r := newProviderConfigResolver(data)
+
+	cfg := api.ClientConfig{
+		GateEndpoint:     r.String("gate_endpoint", false, "SPINNAKER_GATE_ENDPOINT", "GATE_ENDPOINT"),
+		ConfigPath:       r.String("config", false, "SPINNAKER_CONFIG_PATH"),
+		DefaultHeaders:   data.Get("default_headers").(string),
+		Headers:          r.StringMap("headers", "SPINNAKER_HEADERS"),
+		HeadersFromEnv:   r.StringMap("headers_from_env", "SPINNAKER_HEADERS_FROM_ENV"),
+		IgnoreCertErrors: r.Bool("ignore_cert_errors", "SPINNAKER_IGNORE_CERT_ERRORS"),
+		IgnoreRedirects:  r.Bool("ignore_redirects", "SPINNAKER_IGNORE_REDIRECTS"),
+		Retry:            expandProviderRetryFromEnv(r),
+		Limit: api.LimitConfig{
+			RequestsPerSecond:  r.Float("max_requests_per_second", 0, "SPINNAKER_MAX_REQUESTS_PER_SECOND"),
+			ConcurrentRequests: r.Int("max_concurrent_requests", 0, "SPINNAKER_MAX_CONCURRENT_REQUESTS"),
+		},
+		ClientCert: r.String("client_cert", false, "SPINNAKER_CLIENT_CERT"),
+		ClientKey:  r.String("client_key", true, "SPINNAKER_CLIENT_KEY"),
+		CABundle:   r.String("ca_bundle", false, "SPINNAKER_CA_BUNDLE"),
+		RunAsUser:  r.String("run_as_user", false, "SPINNAKER_RUN_AS_USER"),
+		Output: func(msg string) {
+			tflog.Info(ctx, msg)
+		},
+		Context: ctx,
+	}
+	r.Require("client_cert", "client_key")
+	r.Require("client_key", "client_cert")
+	expandProviderAuth(r, &cfg)
+
+	// The spin config file and the defaults come last, the Gate client
+	// applies them, they are only recorded here
+	spinConfig, err := api.LoadSpinConfig(cfg.ConfigPath)
+	if err != nil {
+		source := sourceDefault
+		if r.Resolved("config") {
+			source = r.Source("config")
+		}
+		r.fail("config", source, err)
+		return cfg, r, r.Err()
+	}
+
+	spinConfigSource := sourceSpinConfig
+	if cfg.ConfigPath != "" {
+		spinConfigSource += " " + cfg.ConfigPath
+	}
+	if spinConfig.Gate.Endpoint != "" {
+		r.Default("gate_endpoint", spinConfigSource, spinConfig.Gate.Endpoint)
+	}
+	r.Default("gate_endpoint", sourceDefault, api.DefaultGateEndpoint)
+	if spinConfig.Auth != nil && spinConfig.Auth.Enabled && !r.Resolved("access_token") && !r.Resolved("auth.oauth2") && !r.Resolved("auth.basic") {
+		r.Default("auth", spinConfigSource, "enabled")
+	}
+
+	return cfg, r, r.Err()
+}
+
+func getProviderAuthSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"oauth2": {
+			Type:          schema.TypeList,
+			Optional:      true,
+			MaxItems:      1,
+			Description:   "OAuth2 client credentials used to obtain bearer tokens, tokens are refreshed when they expire",
+			ConflictsWith: []string{"auth.0.basic"},
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"token_url": {
+						Type:        schema.TypeString,
+						Required:    true,
+						Description: "Token endpoint of the OAuth2 / OIDC provider",
+					},
+					"client_id": {
+						Type:        schema.TypeString,
+						Required:    true,
+						Description: "OAuth2 client ID",
+					},
+					"client_secret": {
+						Type:        schema.TypeString,
+						Required:    true,
+						Sensitive:   true,
+						Description: "OAuth2 client secret",
+					},
+					"scopes": {
+						Type:        schema.TypeList,
+						Optional:    true,
+						Description: "Scopes to request",
+						Elem:        &schema.Schema{Type: schema.TypeString},
+					},
+				},
+			},
+		},
+		"basic": {
+			Type:        schema.TypeList,
+			Optional:    true,
+			MaxItems:    1,
+			Description: "Username and password used to log in to Gate's /login form (LDAP or basic auth), the session is renewed when Gate answers 401",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"username": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "Username to log in with",
+					},
+					"password": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Sensitive:   true,
+						Description: "Password to log in with",
+					},
+				},
+			},
+		},
+	}
+}
+
+func expandProviderOAuth2(vs []interface{}) *api.OAuth2Config {
+	if len(vs) == 0 || vs[0] == nil {
+		return nil
+	}
+
+	v := vs[0].(map[string]interface{})
+	scopes := []string{}
+	for _, scope := range v["scopes"].([]interface{}) {
+		scopes = append(scopes, scope.(string))
+	}
+
+	return &api.OAuth2Config{
+		TokenURL:     v["token_url"].(string),
+		ClientID:     v["client_id"].(string),
+		ClientSecret: v["client_secret"].(string),
+		Scopes:       scopes,
+	}
+}
+
+func expandProviderBasicAuth(vs []interface{}) *api.BasicAuthConfig {
+	if len(vs) == 0 {
+		return nil
+	}
+
+	// An empty block is a nil element, expandProviderAuth takes its
+	// attributes from the environment then
+	v, _ := vs[0].(map[string]interface{})
+	username, _ := v["username"].(string)
+	password, _ := v["password"].(string)
+
+	return &api.BasicAuthConfig{
+		Username: username,
+		Password: password,
+	}
+}
+
+func expandStringMap(m map[string]interface{}) map[string]string {
+	result := make(map[string]string, len(m))
+	for k, v := range m {
+		result[k] = v.(string)
+	}
+
+	return result
+}
+
+func getProviderRetrySchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"max_attempts": {
+			Type:         schema.TypeInt,
+			Optional:     true,
+			Default:      api.DefaultRetryMaxAttempts,
+			Description:  "Number of times a request is sent, 1 disables retries",
+			ValidateFunc: validation.IntAtLeast(1),
+		},
+		"base_delay": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			Default:      api.DefaultRetryBaseDelay.String(),
+			Description:  "Delay before the first retry, doubled on each retry with jitter",
+			ValidateFunc: validateSpinnakerDuration,
+		},
+		"max_delay": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			Default:      api.DefaultRetryMaxDelay.String(),
+			Description:  "Maximum delay between two attempts, Retry-After included",
+			ValidateFunc: validateSpinnakerDuration,
+		},
+	}
+}
+
+func expandProviderRetry(vs []interface{}) api.RetryConfig {
+	if len(vs) == 0 || vs[0] == nil {
+		return api.DefaultRetryConfig()
+	}
+
+	v := vs[0].(map[string]interface{})
+	// durations are validated by the schema
+	baseDelay, _ := time.ParseDuration(v["base_delay"].(string))
+	maxDelay, _ := time.ParseDuration(v["max_delay"].(string))
+
+	return api.RetryConfig{
+		MaxAttempts: v["max_attempts"].(int),
+		BaseDelay:   baseDelay,
+		MaxDelay:    maxDelay,
+	}
+}
+
+// diagFromErr is diag.FromErr with the failed step, the exception and the
+// link of a failed Orca task in the detail of the diagnostic
+func diagFromErr(err error) diag.Diagnostics {
+	var taskErr *api.TaskError
+	if !errors.As(err, &taskErr) {
+		return diag.FromErr(err)
+	}
+
+	detail := []string{fmt.Sprintf("Task: %s (%s)", taskErr.ID, taskErr.URL)}
+	if taskErr.Step != "" {
+		detail = append(detail, "Failed step: "+taskErr.Step)
+	}
+	if taskErr.Message != "" {
+		detail = append(detail, "Exception: "+taskErr.Message)
+	}
+
+	return diag.Diagnostics{{
+		Severity: diag.Error,
+		Summary:  fmt.Sprintf("Orca task %s ended with status %s", taskErr.ID, taskErr.Status),
+		Detail:   strings.Join(detail, "\n"),
+	}}
 }
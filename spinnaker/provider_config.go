@@ -0,0 +1,405 @@
+package spinnaker
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	api "github.com/himanhsugusain/terraform-provider-spinnaker/spinnaker/api"
+)
+
+// The sources of the settings of the provider, in order of precedence. The
+// environment variables are named after the attributes, such as
+// SPINNAKER_CA_BUNDLE for ca_bundle.
+const (
+	sourceProviderBlock = "provider block"
+	sourceEnv           = "environment variable %s"
+	sourceSpinConfig    = "spin config file"
+	sourceDefault       = "default"
+)
+
+// providerConfigSource is where a setting of the provider comes from
+type providerConfigSource struct {
+	name   string
+	source string
+	// value is empty for the sensitive settings
+	value string
+}
+
+// providerConfigResolver looks up the settings of the provider in the
+// provider block, then in the SPINNAKER_* environment variables, and records
+// where each one comes from. The spin config file and the defaults come last,
+// they are applied by the Gate client.
+type providerConfigResolver struct {
+	data    *schema.ResourceData
+	sources map[string]providerConfigSource
+	errs    []error
+}
+
+func newProviderConfigResolver(data *schema.ResourceData) *providerConfigResolver {
+	return &providerConfigResolver{data: data, sources: map[string]providerConfigSource{}}
+}
+
+// lookup returns the raw value of the setting, from the provider block when
+// set there, otherwise the string of the first environment variable set. The
+// settings of a nested block, such as retry.max_attempts, are only looked up
+// in the environment, the block is read as a whole.
+func (r *providerConfigResolver) lookup(name string, envs ...string) (interface{}, string, bool) {
+	// GetOkExists tells an explicit false or 0 of the provider block from an
+	// unset attribute, the environment must not override the former
+	if !strings.Contains(name, ".") {
+		if v, ok := r.data.GetOkExists(name); ok {
+			return v, sourceProviderBlock, true
+		}
+	}
+
+	for _, env := range envs {
+		if v, ok := os.LookupEnv(env); ok && v != "" {
+			return v, fmt.Sprintf(sourceEnv, env), true
+		}
+	}
+	return nil, "", false
+}
+
+func (r *providerConfigResolver) record(name, source string, value interface{}, sensitive bool) {
+	s := providerConfigSource{name: name, source: source}
+	if !sensitive {
+		s.value = fmt.Sprint(value)
+	}
+	r.sources[name] = s
+}
+
+// fail records an error of the setting, with where its value comes from
+func (r *providerConfigResolver) fail(name, source string, err error) {
+	r.errs = append(r.errs, fmt.Errorf("%s (%s): %s", name, source, err))
+}
+
+func (r *providerConfigResolver) String(name string, sensitive bool, envs ...string) string {
+	v, source, ok := r.lookup(name, envs...)
+	if !ok {
+		return ""
+	}
+
+	r.record(name, source, v, sensitive)
+	return v.(string)
+}
+
+func (r *providerConfigResolver) Bool(name string, envs ...string) bool {
+	v, source, ok := r.lookup(name, envs...)
+	if !ok {
+		return false
+	}
+
+	b, isBool := v.(bool)
+	if !isBool {
+		var err error
+		if b, err = strconv.ParseBool(v.(string)); err != nil {
+			r.fail(name, source, fmt.Errorf("%q is not a boolean", v))
+			return false
+		}
+	}
+
+	r.record(name, source, b, false)
+	return b
+}
+
+func (r *providerConfigResolver) Int(name string, min int, envs ...string) int {
+	v, source, ok := r.lookup(name, envs...)
+	if !ok {
+		return 0
+	}
+
+	i, isInt := v.(int)
+	if !isInt {
+		var err error
+		if i, err = strconv.Atoi(v.(string)); err != nil {
+			r.fail(name, source, fmt.Errorf("%q is not an integer", v))
+			return 0
+		}
+	}
+	if i < min {
+		r.fail(name, source, fmt.Errorf("expected to be at least %d, got %d", min, i))
+		return 0
+	}
+
+	r.record(name, source, i, false)
+	return i
+}
+
+func (r *providerConfigResolver) Float(name string, min float64, envs ...string) float64 {
+	v, source, ok := r.lookup(name, envs...)
+	if !ok {
+		return 0
+	}
+
+	f, isFloat := v.(float64)
+	if !isFloat {
+		var err error
+		if f, err = strconv.ParseFloat(v.(string), 64); err != nil {
+			r.fail(name, source, fmt.Errorf("%q is not a number", v))
+			return 0
+		}
+	}
+	if f < min {
+		r.fail(name, source, fmt.Errorf("expected to be at least %v, got %v", min, f))
+		return 0
+	}
+
+	r.record(name, source, f, false)
+	return f
+}
+
+func (r *providerConfigResolver) Duration(name string, envs ...string) time.Duration {
+	v, source, ok := r.lookup(name, envs...)
+	if !ok {
+		return 0
+	}
+
+	d, err := time.ParseDuration(v.(string))
+	if err != nil {
+		r.fail(name, source, fmt.Errorf("%q is not a duration", v))
+		return 0
+	}
+
+	r.record(name, source, d, false)
+	return d
+}
+
+// StringMap reads a map attribute, or an environment variable of comma
+// separated name=value pairs
+func (r *providerConfigResolver) StringMap(name string, envs ...string) map[string]string {
+	v, source, ok := r.lookup(name, envs...)
+	if !ok {
+		return map[string]string{}
+	}
+
+	m, isMap := v.(map[string]interface{})
+	if !isMap {
+		var err error
+		if m, err = parseStringMap(v.(string)); err != nil {
+			r.fail(name, source, err)
+			return map[string]string{}
+		}
+	}
+
+	// Only the names of the headers are shown, their values may be secrets
+	headers := expandStringMap(m)
+	r.record(name, source, strings.Join(sortedKeys(headers), ","), false)
+	return headers
+}
+
+// Require fails when the setting is set without the one it requires
+func (r *providerConfigResolver) Require(name, required string) {
+	s, ok := r.sources[name]
+	if _, isSet := r.sources[required]; ok && !isSet {
+		r.fail(name, s.source, fmt.Errorf("%s is required as well", required))
+	}
+}
+
+// Resolved tells whether the setting has a value
+func (r *providerConfigResolver) Resolved(name string) bool {
+	_, ok := r.sources[name]
+	return ok
+}
+
+// Source returns where the setting comes from
+func (r *providerConfigResolver) Source(name string) string {
+	return r.sources[name].source
+}
+
+// Default records that the setting comes from the spin config file or the
+// defaults of the provider
+func (r *providerConfigResolver) Default(name, source string, value interface{}) {
+	if !r.Resolved(name) {
+		r.record(name, source, value, false)
+	}
+}
+
+// Err returns the errors of the settings
+func (r *providerConfigResolver) Err() error {
+	if len(r.errs) == 0 {
+		return nil
+	}
+
+	msgs := make([]string, 0, len(r.errs))
+	for _, err := range r.errs {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Errorf("invalid provider configuration:\n  %s", strings.Join(msgs, "\n  "))
+}
+
+// Sources returns the settings of the provider and where they come from, for
+// the logs
+func (r *providerConfigResolver) Sources() map[string]interface{} {
+	fields := make(map[string]interface{}, len(r.sources))
+	for name, s := range r.sources {
+		fields[name] = s.source
+	}
+	return fields
+}
+
+// Diagnostics returns err, failing the configuration of the provider, with
+// the settings of the provider and where they come from in the detail
+func (r *providerConfigResolver) Diagnostics(err error) diag.Diagnostics {
+	names := make([]string, 0, len(r.sources))
+	for name := range r.sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	detail := []string{"Settings of the provider and where they come from:"}
+	for _, name := range names {
+		s := r.sources[name]
+		if s.value == "" {
+			detail = append(detail, fmt.Sprintf("  %s: %s", name, s.source))
+			continue
+		}
+		detail = append(detail, fmt.Sprintf("  %s = %s: %s", name, s.value, s.source))
+	}
+
+	return diag.Diagnostics{{
+		Severity: diag.Error,
+		Summary:  err.Error(),
+		Detail:   strings.Join(detail, "\n"),
+	}}
+}
+
+// expandProviderAuth resolves the authentication, all of it comes from the
+// first source configuring any: the provider block, then the environment,
+// then the spin config file
+func expandProviderAuth(r *providerConfigResolver, cfg *api.ClientConfig) {
+	if v, ok := r.data.GetOk("access_token"); ok {
+		cfg.AccessToken = v.(string)
+		r.record("access_token", sourceProviderBlock, nil, true)
+	}
+	if vs := r.data.Get("auth.0.oauth2").([]interface{}); len(vs) > 0 {
+		cfg.OAuth2 = expandProviderOAuth2(vs)
+		r.record("auth.oauth2", sourceProviderBlock, cfg.OAuth2.TokenURL, false)
+	}
+	if vs := r.data.Get("auth.0.basic").([]interface{}); len(vs) > 0 {
+		cfg.Basic = expandProviderBasicAuth(vs)
+		// The username and password the block omits come from the environment
+		if cfg.Basic.Username == "" {
+			cfg.Basic.Username = r.String("auth.basic.username", false, "SPINNAKER_USERNAME")
+		}
+		if cfg.Basic.Password == "" {
+			cfg.Basic.Password = r.String("auth.basic.password", true, "SPINNAKER_PASSWORD")
+		}
+		r.record("auth.basic", sourceProviderBlock, cfg.Basic.Username, false)
+	}
+	if cfg.AccessToken != "" || cfg.OAuth2 != nil || cfg.Basic != nil {
+		return
+	}
+
+	methods := []string{}
+	if token := os.Getenv("SPINNAKER_ACCESS_TOKEN"); token != "" {
+		cfg.AccessToken = token
+		r.record("access_token", fmt.Sprintf(sourceEnv, "SPINNAKER_ACCESS_TOKEN"), nil, true)
+		methods = append(methods, "access_token")
+	}
+	if tokenURL := os.Getenv("SPINNAKER_OAUTH2_TOKEN_URL"); tokenURL != "" {
+		source := fmt.Sprintf(sourceEnv, "SPINNAKER_OAUTH2_TOKEN_URL")
+		cfg.OAuth2 = &api.OAuth2Config{
+			TokenURL:     tokenURL,
+			ClientID:     os.Getenv("SPINNAKER_OAUTH2_CLIENT_ID"),
+			ClientSecret: os.Getenv("SPINNAKER_OAUTH2_CLIENT_SECRET"),
+			Scopes:       []string{},
+		}
+		for _, scope := range strings.Split(os.Getenv("SPINNAKER_OAUTH2_SCOPES"), ",") {
+			if scope = strings.TrimSpace(scope); scope != "" {
+				cfg.OAuth2.Scopes = append(cfg.OAuth2.Scopes, scope)
+			}
+		}
+		r.record("auth.oauth2", source, tokenURL, false)
+		for _, env := range []string{"SPINNAKER_OAUTH2_CLIENT_ID", "SPINNAKER_OAUTH2_CLIENT_SECRET"} {
+			if os.Getenv(env) == "" {
+				r.fail("auth.oauth2", source, fmt.Errorf("%s is required as well", env))
+			}
+		}
+		methods = append(methods, "auth.oauth2")
+	}
+	if username := os.Getenv("SPINNAKER_USERNAME"); username != "" {
+		source := fmt.Sprintf(sourceEnv, "SPINNAKER_USERNAME")
+		cfg.Basic = &api.BasicAuthConfig{Username: username, Password: os.Getenv("SPINNAKER_PASSWORD")}
+		r.record("auth.basic", source, username, false)
+		if cfg.Basic.Password == "" {
+			r.fail("auth.basic", source, fmt.Errorf("SPINNAKER_PASSWORD is required as well"))
+		}
+		methods = append(methods, "auth.basic")
+	}
+
+	if len(methods) > 1 {
+		conflicts := make([]string, 0, len(methods))
+		for _, method := range methods {
+			conflicts = append(conflicts, fmt.Sprintf("%s (%s)", method, r.Source(method)))
+		}
+		r.errs = append(r.errs, fmt.Errorf("only one authentication can be set, got %s", strings.Join(conflicts, ", ")))
+	}
+}
+
+// expandProviderRetryFromEnv returns the retry policy of the retry block, or
+// of the environment
+func expandProviderRetryFromEnv(r *providerConfigResolver) api.RetryConfig {
+	if vs := r.data.Get("retry").([]interface{}); len(vs) > 0 {
+		r.record("retry", sourceProviderBlock, "", false)
+		return expandProviderRetry(vs)
+	}
+
+	retry := api.DefaultRetryConfig()
+	if v := r.Int("retry.max_attempts", 1, "SPINNAKER_RETRY_MAX_ATTEMPTS"); v != 0 {
+		retry.MaxAttempts = v
+	}
+	if v := r.Duration("retry.base_delay", "SPINNAKER_RETRY_BASE_DELAY"); v != 0 {
+		retry.BaseDelay = v
+	}
+	if v := r.Duration("retry.max_delay", "SPINNAKER_RETRY_MAX_DELAY"); v != 0 {
+		retry.MaxDelay = v
+	}
+	return retry
+}
+
+// expandProviderDefaults resolves the values of the arguments the resources
+// omit, keyed by the attribute of the provider
+func expandProviderDefaults(r *providerConfigResolver) map[string]string {
+	defaults := map[string]string{
+		"default_application": r.String("default_application", false, "SPINNAKER_DEFAULT_APPLICATION"),
+		"default_owner_email": r.String("default_owner_email", false, "SPINNAKER_DEFAULT_OWNER_EMAIL"),
+	}
+	if v := defaults["default_application"]; v != "" {
+		if _, errs := validateSpinnakerApplicationName(v, "default_application"); len(errs) > 0 {
+			r.fail("default_application", r.Source("default_application"), errs[0])
+		}
+	}
+	return defaults
+}
+
+// parseStringMap parses comma separated name=value pairs
+func parseStringMap(s string) (map[string]interface{}, error) {
+	m := map[string]interface{}{}
+	for _, element := range strings.Split(s, ",") {
+		if strings.TrimSpace(element) == "" {
+			continue
+		}
+
+		pair := strings.SplitN(element, "=", 2)
+		if len(pair) != 2 {
+			return nil, fmt.Errorf("use name=value pairs separated by commas, got %q", element)
+		}
+		m[strings.TrimSpace(pair[0])] = strings.TrimSpace(pair[1])
+	}
+	return m, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
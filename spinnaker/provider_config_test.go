@@ -0,0 +1,275 @@
+package spinnaker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// testProviderConfigEnv unsets the environment variables of the provider,
+// then sets env
+func testProviderConfigEnv(t *testing.T, env map[string]string) {
+	for _, name := range []string{
+		"GATE_ENDPOINT", "SPINNAKER_GATE_ENDPOINT", "SPINNAKER_CONFIG_PATH", "SPINNAKER_HEADERS", "SPINNAKER_HEADERS_FROM_ENV",
+		"SPINNAKER_IGNORE_CERT_ERRORS", "SPINNAKER_IGNORE_REDIRECTS", "SPINNAKER_RETRY_MAX_ATTEMPTS", "SPINNAKER_RETRY_BASE_DELAY",
+		"SPINNAKER_RETRY_MAX_DELAY", "SPINNAKER_MAX_REQUESTS_PER_SECOND", "SPINNAKER_MAX_CONCURRENT_REQUESTS", "SPINNAKER_CLIENT_CERT",
+		"SPINNAKER_CLIENT_KEY", "SPINNAKER_CA_BUNDLE", "SPINNAKER_RUN_AS_USER", "SPINNAKER_ACCESS_TOKEN", "SPINNAKER_OAUTH2_TOKEN_URL",
+		"SPINNAKER_OAUTH2_CLIENT_ID", "SPINNAKER_OAUTH2_CLIENT_SECRET", "SPINNAKER_OAUTH2_SCOPES", "SPINNAKER_USERNAME", "SPINNAKER_PASSWORD",
+		"SPINNAKER_DEFAULT_APPLICATION", "SPINNAKER_DEFAULT_OWNER_EMAIL",
+	} {
+		t.Setenv(name, "")
+	}
+	for name, value := range env {
+		t.Setenv(name, value)
+	}
+}
+
+func TestExpandProviderClientConfigSources(t *testing.T) {
+	spinConfig := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(spinConfig, []byte("gate:\n  endpoint: https://spin.example.com\n"), 0o600); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	noSpinConfig := filepath.Join(t.TempDir(), "config")
+
+	tcs := map[string]struct {
+		raw      map[string]interface{}
+		env      map[string]string
+		expected map[string]string
+	}{
+		"provider block": {
+			raw: map[string]interface{}{"gate_endpoint": "https://block.example.com", "ignore_cert_errors": false},
+			env: map[string]string{"SPINNAKER_GATE_ENDPOINT": "https://env.example.com", "SPINNAKER_IGNORE_CERT_ERRORS": "true"},
+			expected: map[string]string{
+				"gate_endpoint":      "provider block",
+				"ignore_cert_errors": "provider block",
+			},
+		},
+		"environment": {
+			env: map[string]string{
+				"SPINNAKER_GATE_ENDPOINT":           "https://env.example.com",
+				"GATE_ENDPOINT":                     "https://legacy.example.com",
+				"SPINNAKER_MAX_CONCURRENT_REQUESTS": "4",
+				"SPINNAKER_HEADERS":                 "X-Team=infra, X-Proxy=secret",
+				"SPINNAKER_RETRY_BASE_DELAY":        "1s",
+				"SPINNAKER_OAUTH2_TOKEN_URL":        "https://login.example.com/token",
+				"SPINNAKER_OAUTH2_CLIENT_ID":        "terraform",
+				"SPINNAKER_OAUTH2_CLIENT_SECRET":    "secret",
+			},
+			expected: map[string]string{
+				"gate_endpoint":           "environment variable SPINNAKER_GATE_ENDPOINT",
+				"max_concurrent_requests": "environment variable SPINNAKER_MAX_CONCURRENT_REQUESTS",
+				"headers":                 "environment variable SPINNAKER_HEADERS",
+				"retry.base_delay":        "environment variable SPINNAKER_RETRY_BASE_DELAY",
+				"auth.oauth2":             "environment variable SPINNAKER_OAUTH2_TOKEN_URL",
+			},
+		},
+		"basic block from environment": {
+			raw: map[string]interface{}{
+				"auth": []interface{}{map[string]interface{}{"basic": []interface{}{map[string]interface{}{}}}},
+			},
+			env: map[string]string{"SPINNAKER_USERNAME": "admin", "SPINNAKER_PASSWORD": "secret"},
+			expected: map[string]string{
+				"auth.basic":          "provider block",
+				"auth.basic.username": "environment variable SPINNAKER_USERNAME",
+				"auth.basic.password": "environment variable SPINNAKER_PASSWORD",
+			},
+		},
+		"legacy environment": {
+			env:      map[string]string{"GATE_ENDPOINT": "https://legacy.example.com"},
+			expected: map[string]string{"gate_endpoint": "environment variable GATE_ENDPOINT"},
+		},
+		"spin config": {
+			raw:      map[string]interface{}{"config": spinConfig},
+			expected: map[string]string{"gate_endpoint": "spin config file " + spinConfig, "config": "provider block"},
+		},
+		"default": {
+			expected: map[string]string{"gate_endpoint": "default"},
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			testProviderConfigEnv(t, tc.env)
+			if tc.raw == nil {
+				tc.raw = map[string]interface{}{}
+			}
+			if _, ok := tc.raw["config"]; !ok {
+				tc.raw["config"] = noSpinConfig
+			}
+
+			data := schema.TestResourceDataRaw(t, Provider().Schema, tc.raw)
+			_, r, err := expandProviderClientConfig(context.Background(), data)
+			if err != nil {
+				t.Fatalf("failed: %v", err)
+			}
+
+			for setting, source := range tc.expected {
+				if got := r.Source(setting); got != source {
+					t.Fatalf("expected %s from %s, got %q", setting, source, got)
+				}
+			}
+		})
+	}
+}
+
+func TestExpandProviderClientConfigEnv(t *testing.T) {
+	testProviderConfigEnv(t, map[string]string{
+		"SPINNAKER_GATE_ENDPOINT":           "https://env.example.com",
+		"SPINNAKER_CONFIG_PATH":             filepath.Join(t.TempDir(), "config"),
+		"SPINNAKER_IGNORE_CERT_ERRORS":      "true",
+		"SPINNAKER_MAX_REQUESTS_PER_SECOND": "2.5",
+		"SPINNAKER_HEADERS_FROM_ENV":        "X-Proxy=PROXY_TOKEN",
+		"SPINNAKER_RETRY_MAX_ATTEMPTS":      "2",
+		"SPINNAKER_RUN_AS_USER":             "team-svc",
+		"SPINNAKER_USERNAME":                "admin",
+		"SPINNAKER_PASSWORD":                "secret",
+	})
+
+	data := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{})
+	cfg, _, err := expandProviderClientConfig(context.Background(), data)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	if cfg.GateEndpoint != "https://env.example.com" || !cfg.IgnoreCertErrors || cfg.Limit.RequestsPerSecond != 2.5 || cfg.RunAsUser != "team-svc" {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+	if cfg.HeadersFromEnv["X-Proxy"] != "PROXY_TOKEN" {
+		t.Fatalf("unexpected headers_from_env: %v", cfg.HeadersFromEnv)
+	}
+	if cfg.Retry.MaxAttempts != 2 || cfg.Retry.MaxDelay != 30*time.Second {
+		t.Fatalf("unexpected retry: %+v", cfg.Retry)
+	}
+	if cfg.Basic == nil || cfg.Basic.Username != "admin" || cfg.Basic.Password != "secret" {
+		t.Fatalf("unexpected basic auth: %+v", cfg.Basic)
+	}
+}
+
+func TestExpandProviderClientConfigErrors(t *testing.T) {
+	tcs := map[string]struct {
+		raw      map[string]interface{}
+		env      map[string]string
+		expected string
+	}{
+		"invalid integer": {
+			env:      map[string]string{"SPINNAKER_MAX_CONCURRENT_REQUESTS": "four"},
+			expected: `max_concurrent_requests (environment variable SPINNAKER_MAX_CONCURRENT_REQUESTS): "four" is not an integer`,
+		},
+		"negative rate": {
+			env:      map[string]string{"SPINNAKER_MAX_REQUESTS_PER_SECOND": "-1"},
+			expected: "max_requests_per_second (environment variable SPINNAKER_MAX_REQUESTS_PER_SECOND): expected to be at least 0",
+		},
+		"client certificate without key": {
+			raw:      map[string]interface{}{"client_cert": "cert.pem"},
+			expected: "client_cert (provider block): client_key is required as well",
+		},
+		"invalid headers": {
+			env:      map[string]string{"SPINNAKER_HEADERS": "X-Team"},
+			expected: "headers (environment variable SPINNAKER_HEADERS): use name=value pairs",
+		},
+		"conflicting authentication": {
+			env:      map[string]string{"SPINNAKER_ACCESS_TOKEN": "token", "SPINNAKER_USERNAME": "admin", "SPINNAKER_PASSWORD": "secret"},
+			expected: "only one authentication can be set, got access_token (environment variable SPINNAKER_ACCESS_TOKEN), auth.basic (environment variable SPINNAKER_USERNAME)",
+		},
+		"incomplete oauth2": {
+			env:      map[string]string{"SPINNAKER_OAUTH2_TOKEN_URL": "https://login.example.com/token"},
+			expected: "auth.oauth2 (environment variable SPINNAKER_OAUTH2_TOKEN_URL): SPINNAKER_OAUTH2_CLIENT_ID is required as well",
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			testProviderConfigEnv(t, tc.env)
+			if tc.raw == nil {
+				tc.raw = map[string]interface{}{}
+			}
+			tc.raw["config"] = filepath.Join(t.TempDir(), "config")
+
+			data := schema.TestResourceDataRaw(t, Provider().Schema, tc.raw)
+			_, _, err := expandProviderClientConfig(context.Background(), data)
+			if err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Fatalf("expected %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestExpandProviderDefaults(t *testing.T) {
+	tcs := map[string]struct {
+		raw      map[string]interface{}
+		env      map[string]string
+		expected map[string]string
+		err      string
+	}{
+		"provider block": {
+			raw:      map[string]interface{}{"default_application": "block-app"},
+			env:      map[string]string{"SPINNAKER_DEFAULT_APPLICATION": "env-app", "SPINNAKER_DEFAULT_OWNER_EMAIL": "team@example.com"},
+			expected: map[string]string{"default_application": "block-app", "default_owner_email": "team@example.com"},
+		},
+		"unset": {
+			expected: map[string]string{"default_application": "", "default_owner_email": ""},
+		},
+		"invalid application": {
+			env: map[string]string{"SPINNAKER_DEFAULT_APPLICATION": "team_app"},
+			err: `default_application (environment variable SPINNAKER_DEFAULT_APPLICATION): Only alphanumeric characters or '-' allowed in "default_application"`,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			testProviderConfigEnv(t, tc.env)
+
+			r := newProviderConfigResolver(schema.TestResourceDataRaw(t, Provider().Schema, tc.raw))
+			defaults := expandProviderDefaults(r)
+			if err := r.Err(); tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected %q, got %v", tc.err, err)
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("failed: %v", err)
+			}
+
+			for setting, value := range tc.expected {
+				if defaults[setting] != value {
+					t.Fatalf("expected %s to be %q, got %q", setting, value, defaults[setting])
+				}
+			}
+		})
+	}
+}
+
+func TestProviderConfigDiagnostics(t *testing.T) {
+	testProviderConfigEnv(t, map[string]string{
+		"SPINNAKER_GATE_ENDPOINT": "https://env.example.com",
+		"SPINNAKER_ACCESS_TOKEN":  "static-token",
+	})
+
+	data := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"config":     filepath.Join(t.TempDir(), "config"),
+		"client_key": "key.pem",
+	})
+	_, r, err := expandProviderClientConfig(context.Background(), data)
+	if err == nil {
+		t.Fatal("expected client_key without client_cert to fail")
+	}
+
+	diags := r.Diagnostics(err)
+	for _, expected := range []string{
+		"gate_endpoint = https://env.example.com: environment variable SPINNAKER_GATE_ENDPOINT",
+		"access_token: environment variable SPINNAKER_ACCESS_TOKEN",
+		"client_key: provider block",
+	} {
+		if !strings.Contains(diags[0].Detail, expected) {
+			t.Fatalf("expected %q in the detail, got %q", expected, diags[0].Detail)
+		}
+	}
+	if strings.Contains(diags[0].Detail, "static-token") || strings.Contains(diags[0].Detail, "key.pem") {
+		t.Fatalf("expected the sensitive values to be hidden, got %q", diags[0].Detail)
+	}
+}
@@ -2,11 +2,24 @@ package spinnaker
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	ctyjson "github.com/hashicorp/go-cty/cty/json"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	api "github.com/himanhsugusain/terraform-provider-spinnaker/spinnaker/api"
+	"github.com/himanhsugusain/terraform-provider-spinnaker/spinnaker/fakegate"
 )
 
 var testAccProviders map[string]*schema.Provider
@@ -29,8 +42,269 @@ func testAccPreCheck(t *testing.T) {
 	}
 }
 
+// testUnitPreCheck skips the resource.UnitTest tests when there is no
+// terraform CLI for the test framework to run, outside of CI. `make test`
+// sets TF_ACC_TERRAFORM_VERSION, the framework then installs that version.
+func testUnitPreCheck(t *testing.T) {
+	if os.Getenv("TF_ACC_TERRAFORM_PATH") != "" || os.Getenv("TF_ACC_TERRAFORM_VERSION") != "" {
+		return
+	}
+	if _, err := exec.LookPath("terraform"); err == nil {
+		return
+	}
+	if os.Getenv("CI") != "" {
+		t.Fatal("terraform CLI not found, run `make test` or set TF_ACC_TERRAFORM_PATH or TF_ACC_TERRAFORM_VERSION")
+	}
+	t.Skip("terraform CLI not found, run `make test` or set TF_ACC_TERRAFORM_PATH or TF_ACC_TERRAFORM_VERSION to run the unit tests of the resources")
+}
+
+// testUnitTest runs the steps with the provider configured with the fake Gate,
+// checkDestroy checks the fake once they are destroyed
+func testUnitTest(t *testing.T, gate *fakegate.Gate, checkDestroy resource.TestCheckFunc, steps ...resource.TestStep) {
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:          func() { testUnitPreCheck(t) },
+		ProviderFactories: testUnitProviderFactories(gate),
+		CheckDestroy:      checkDestroy,
+		Steps:             steps,
+	})
+}
+
+// testUnitCheckDeleted checks that read, a read of the fake Gate, finds
+// nothing, such as once the resource of what is destroyed
+func testUnitCheckDeleted(what string, read func() error) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		if err := read(); !errors.Is(err, api.ErrCodeNoSuchEntityException) {
+			return fmt.Errorf("expected %s to be deleted, got %v", what, err)
+		}
+		return nil
+	}
+}
+
+// testUnitProviderFactories returns the provider configured with the fake Gate
+func testUnitProviderFactories(gate *fakegate.Gate) map[string]func() (*schema.Provider, error) {
+	return map[string]func() (*schema.Provider, error){
+		"spinnaker": func() (*schema.Provider, error) {
+			p := Provider()
+			p.ConfigureContextFunc = func(context.Context, *schema.ResourceData) (interface{}, diag.Diagnostics) {
+				return testUnitMeta(gate), nil
+			}
+			return p, nil
+		},
+	}
+}
+
+// testUnitMeta returns the meta of the resources with the fake Gate, its reads
+// are cached like the ones of the provider
+func testUnitMeta(gate *fakegate.Gate) interface{} {
+	return gateConfig{client: api.NewCachedClient(gate.Client())}
+}
+
+// testAccCassette is the provider of an acceptance test which, with
+// RECORD=true, records the interactions with the Gate of GATE_ENDPOINT to
+// testdata/cassettes and, without GATE_ENDPOINT, replays them offline
+type testAccCassette struct {
+	t        *testing.T
+	recorder *api.Recorder
+	provider *schema.Provider
+}
+
+func newTestAccCassette(t *testing.T) *testAccCassette {
+	c := &testAccCassette{t: t, provider: Provider()}
+	path := filepath.Join("testdata", "cassettes", t.Name()+".json")
+
+	var mode api.CassetteMode
+	switch {
+	case os.Getenv("RECORD") == "true":
+		mode = api.CassetteRecord
+	case os.Getenv("GATE_ENDPOINT") != "":
+	default:
+		if _, err := os.Stat(path); err != nil {
+			t.Skipf("GATE_ENDPOINT is not set and there is no cassette %s, record it with `make cassettes`", path)
+		}
+		mode = api.CassetteReplay
+	}
+
+	if mode != 0 {
+		recorder, err := api.NewRecorder(path, mode)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		c.recorder = recorder
+	}
+
+	c.provider.ConfigureContextFunc = func(ctx context.Context, data *schema.ResourceData) (interface{}, diag.Diagnostics) {
+		cfg, r, err := expandProviderClientConfig(ctx, data)
+		if err != nil {
+			return nil, r.Diagnostics(err)
+		}
+		cfg.Cassette = c.recorder
+		client, version, err := api.NewGateClientVersion(cfg)
+		if err != nil {
+			return nil, r.Diagnostics(err)
+		}
+
+		return newGateConfig(client, version), nil
+	}
+
+	return c
+}
+
+func (c *testAccCassette) PreCheck() {
+	if c.recorder != nil && c.recorder.Mode() == api.CassetteReplay {
+		return
+	}
+	testAccPreCheck(c.t)
+}
+
+func (c *testAccCassette) Providers() map[string]*schema.Provider {
+	return map[string]*schema.Provider{
+		"spinnaker": c.provider,
+	}
+}
+
+// RandomName returns a random name, or with a cassette the same name on
+// every run since the requests replayed must match the recorded ones
+func (c *testAccCassette) RandomName(prefix string) string {
+	if c.recorder == nil {
+		return acctest.RandomWithPrefix(prefix)
+	}
+	return fmt.Sprintf("%s-%d", prefix, crc32.ChecksumIEEE([]byte(c.t.Name()+prefix)))
+}
+
+// RandIntRange returns a random int in [min, max), stable with a cassette
+// like RandomName
+func (c *testAccCassette) RandIntRange(min, max int) int {
+	if c.recorder == nil {
+		return acctest.RandIntRange(min, max)
+	}
+	return min + int(crc32.ChecksumIEEE([]byte(c.t.Name()))%uint32(max-min))
+}
+
 func TestProvider(t *testing.T) {
 	if err := Provider().InternalValidate(); err != nil {
 		t.Fatalf("err: %s", err)
 	}
 }
+
+func TestDiagFromErr(t *testing.T) {
+	diags := diagFromErr(fmt.Errorf("could not create application: %w", &api.TaskError{
+		ID:      "01ABC",
+		Status:  "TERMINAL",
+		Step:    "upsertApplication",
+		Message: "Access denied to application",
+		URL:     "https://gate.example.com/tasks/01ABC",
+	}))
+
+	if len(diags) != 1 || !diags.HasError() {
+		t.Fatalf("expected a single error, got %v", diags)
+	}
+
+	for _, expected := range []string{"https://gate.example.com/tasks/01ABC", "upsertApplication", "Access denied to application"} {
+		if !strings.Contains(diags[0].Detail, expected) {
+			t.Fatalf("expected %q in the detail, got %q", expected, diags[0].Detail)
+		}
+	}
+
+	if diags := diagFromErr(errors.New("boom")); diags[0].Summary != "boom" {
+		t.Fatalf("expected other errors as is, got %v", diags)
+	}
+}
+
+func TestCustomizeDiffDefault(t *testing.T) {
+	defaults := map[string]string{"default_application": "team-app", "default_owner_email": "team@example.com"}
+
+	tcs := map[string]struct {
+		resource *schema.Resource
+		state    map[string]string
+		raw      map[string]interface{}
+		defaults map[string]string
+		key      string
+		expected string
+		err      string
+	}{
+		"default": {
+			resource: resourceSpinnakerProject(),
+			raw:      map[string]interface{}{"name": "project"},
+			defaults: defaults,
+			key:      "email",
+			expected: "team@example.com",
+		},
+		"configured": {
+			resource: resourceSpinnakerProject(),
+			raw:      map[string]interface{}{"name": "project", "email": "owner@example.com"},
+			defaults: defaults,
+			key:      "email",
+			expected: "owner@example.com",
+		},
+		"no default": {
+			resource: resourceSpinnakerProject(),
+			raw:      map[string]interface{}{"name": "project"},
+			err:      "email is required, set it or the default_owner_email of the provider",
+		},
+		"no default for an existing resource": {
+			resource: resourceSpinnakerProject(),
+			state:    map[string]string{"id": "project", "name": "project", "email": "owner@example.com"},
+			raw:      map[string]interface{}{"name": "project"},
+			key:      "email",
+		},
+		"changed default": {
+			resource: resourcePipeline(),
+			state:    map[string]string{"id": "01ABC", "name": "deploy", "application": "other-app", "pipeline": "{}"},
+			raw:      map[string]interface{}{"name": "deploy", "pipeline": "{}"},
+			defaults: defaults,
+			key:      "application",
+			expected: "team-app",
+		},
+		"deprecated alias": {
+			resource: resourceSpinnakerApplication(),
+			raw:      map[string]interface{}{"application": "app", "email": "owner@example.com"},
+			defaults: defaults,
+			key:      "name",
+		},
+	}
+
+	for name, tc := range tcs {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			b, err := json.Marshal(tc.raw)
+			if err != nil {
+				t.Fatalf("failed: %v", err)
+			}
+			rawConfig, err := ctyjson.Unmarshal(b, tc.resource.CoreConfigSchema().ImpliedType())
+			if err != nil {
+				t.Fatalf("failed: %v", err)
+			}
+			state := &terraform.InstanceState{Attributes: tc.state, RawConfig: rawConfig}
+			if tc.state != nil {
+				state.ID = tc.state["id"]
+			}
+
+			diff, err := tc.resource.Diff(context.Background(), state, terraform.NewResourceConfigRaw(tc.raw), gateConfig{defaults: tc.defaults})
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Fatalf("expected %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed: %v", err)
+			}
+
+			var attr *terraform.ResourceAttrDiff
+			if diff != nil {
+				attr = diff.Attributes[tc.key]
+			}
+			if tc.expected == "" {
+				if attr != nil && attr.New != "" {
+					t.Fatalf("expected no value planned for %s, got %+v", tc.key, attr)
+				}
+				return
+			}
+			if attr == nil || attr.New != tc.expected {
+				t.Fatalf("expected %s to be planned as %s, got %+v", tc.key, tc.expected, attr)
+			}
+		})
+	}
+}
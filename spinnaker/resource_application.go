@@ -6,9 +6,11 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 
 	api "github.com/himanhsugusain/terraform-provider-spinnaker/spinnaker/api"
@@ -31,15 +33,17 @@ func resourceSpinnakerApplication() *schema.Resource {
 				ValidateFunc:  validateSpinnakerApplicationName,
 			},
 			"name": {
-				Description:  "Name of the Application",
+				Description:  "Name of the Application, defaults to the default_application of the provider",
 				Type:         schema.TypeString,
 				Optional:     true,
+				Computed:     true,
 				ValidateFunc: validateSpinnakerApplicationName,
 			},
 			"email": {
-				Description: "Email of the owner",
+				Description: "Email of the owner, defaults to the default_owner_email of the provider",
 				Type:        schema.TypeString,
-				Required:    true,
+				Optional:    true,
+				Computed:    true,
 			},
 			"cloud_providers": {
 				Description: "Cloud providers that is used by the application",
@@ -63,7 +67,16 @@ func resourceSpinnakerApplication() *schema.Resource {
 					Schema: getApplicationPermissionSchema(),
 				},
 			},
+			"run_as_user": {
+				Description: "User, usually the service account of the owning team, the application is written as instead of the provider's run_as_user",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
 		},
+		CustomizeDiff: customdiff.All(
+			customizeDiffDefault("name", "default_application", "application"),
+			customizeDiffDefault("email", "default_owner_email"),
+		),
 		CreateContext: resourceSpinnakerApplicationCreate,
 		ReadContext:   resourceSpinnakerApplicationRead,
 		UpdateContext: resourceSpinnakerApplicationUpdate,
@@ -71,9 +84,22 @@ func resourceSpinnakerApplication() *schema.Resource {
 		Importer: &schema.ResourceImporter{
 			StateContext: resourceSpinnakerApplicationImport,
 		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(10 * time.Minute),
+			Update: schema.DefaultTimeout(10 * time.Minute),
+			Delete: schema.DefaultTimeout(20 * time.Minute),
+		},
 	}
 }
 
+// applicationClient returns the client the application is managed with, the
+// application data source shares the read of the resource and has no run_as_user
+func applicationClient(ctx context.Context, d *schema.ResourceData, meta interface{}) api.Client {
+	clientConfig := meta.(gateConfig)
+	runAsUser, _ := d.Get("run_as_user").(string)
+	return clientConfig.client.WithRunAsUser(runAsUser).WithContext(ctx)
+}
+
 type applicationRead struct {
 	Name       string                 `json:"name"`
 	Attributes *applicationAttributes `json:"attributes"`
@@ -94,9 +120,8 @@ type Permissions struct {
 }
 
 func resourceSpinnakerApplicationCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	clientConfig := meta.(gateConfig)
 	var diags diag.Diagnostics
-	client := clientConfig.client
+	client := applicationClient(ctx, d, meta)
 	appName := api.GetApplicationName(d)
 
 	task, err := api.NewCreateApplicationTask(d)
@@ -104,8 +129,8 @@ func resourceSpinnakerApplicationCreate(ctx context.Context, d *schema.ResourceD
 		return diag.FromErr(err)
 	}
 
-	if err := api.CreateApplication(client, task); err != nil {
-		return diag.FromErr(err)
+	if err := client.CreateApplication(task); err != nil {
+		return diagFromErr(err)
 	}
 
 	d.SetId(appName)
@@ -113,13 +138,12 @@ func resourceSpinnakerApplicationCreate(ctx context.Context, d *schema.ResourceD
 }
 
 func resourceSpinnakerApplicationRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	clientConfig := meta.(gateConfig)
 	var diags diag.Diagnostics
-	client := clientConfig.client
+	client := applicationClient(ctx, d, meta)
 	appName := api.GetApplicationName(d)
 
 	app := &applicationRead{}
-	if err := api.GetApplication(client, appName, app); err != nil {
+	if err := client.GetApplication(appName, app); err != nil {
 		// application does not exists, create new
 		if errors.Is(err, api.ErrCodeNoSuchEntityException) {
 			d.SetId("")
@@ -165,27 +189,25 @@ func resourceSpinnakerApplicationRead(ctx context.Context, d *schema.ResourceDat
 }
 
 func resourceSpinnakerApplicationUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	clientConfig := meta.(gateConfig)
-	client := clientConfig.client
+	client := applicationClient(ctx, d, meta)
 	task, err := api.NewCreateApplicationTask(d)
 	if err != nil {
 		return diag.FromErr(err)
 	}
 
-	if err := api.CreateApplication(client, task); err != nil {
-		return diag.FromErr(err)
+	if err := client.CreateApplication(task); err != nil {
+		return diagFromErr(err)
 	}
 	return resourceSpinnakerApplicationRead(ctx, d, meta)
 }
 
 func resourceSpinnakerApplicationDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	clientConfig := meta.(gateConfig)
 	var diags diag.Diagnostics
-	client := clientConfig.client
+	client := applicationClient(ctx, d, meta)
 	appName := api.GetApplicationName(d)
 
-	if err := api.DeleteApplication(client, appName); err != nil {
-		return diag.FromErr(err)
+	if err := client.DeleteApplication(appName); err != nil {
+		return diagFromErr(err)
 	}
 
 	d.SetId("")
@@ -193,7 +215,7 @@ func resourceSpinnakerApplicationDelete(ctx context.Context, d *schema.ResourceD
 }
 
 func resourceSpinnakerApplicationImport(ctx context.Context, d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
-	if diags := resourceSpinnakerApplicationRead(context.Background(), d, meta); diags.HasError() {
+	if diags := resourceSpinnakerApplicationRead(ctx, d, meta); diags.HasError() {
 		return nil, fmt.Errorf("failed to read spinnaker application")
 	}
 	return []*schema.ResourceData{d}, nil
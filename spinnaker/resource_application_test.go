@@ -1,32 +1,35 @@
 package spinnaker
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"net/http"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
 
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 
 	"github.com/himanhsugusain/terraform-provider-spinnaker/spinnaker/api"
+	"github.com/himanhsugusain/terraform-provider-spinnaker/spinnaker/fakegate"
 )
 
 func TestAccResourceSourceSpinnakerApplication_basic(t *testing.T) {
+	cassette := newTestAccCassette(t)
 	resourceName := "spinnaker_application.test"
-	rName := acctest.RandomWithPrefix("tf-acc-test")
+	rName := cassette.RandomName("tf-acc-test")
 	resource.ParallelTest(t, resource.TestCase{
-		PreCheck:     func() { testAccPreCheck(t) },
-		Providers:    testAccProviders,
-		CheckDestroy: testAccCheckSpinnakerApplicatioDestroy(t, resourceName),
+		PreCheck:     cassette.PreCheck,
+		Providers:    cassette.Providers(),
+		CheckDestroy: testAccCheckSpinnakerApplicatioDestroy(t, cassette.provider, resourceName),
 		Steps: []resource.TestStep{
 			{
 				Config: testAccSpinnakerApplication_basic(rName),
 				Check: resource.ComposeTestCheckFunc(
-					testAccCheckSpinnakerApplicationExists(resourceName),
+					testAccCheckSpinnakerApplicationExists(cassette.provider, resourceName),
 					resource.TestCheckResourceAttr(resourceName, "name", rName),
 					resource.TestCheckResourceAttr(resourceName, "email", "acceptance@test.com"),
 					resource.TestCheckResourceAttr(resourceName, "instance_port", strconv.Itoa(defaultInstancePort)),
@@ -37,18 +40,19 @@ func TestAccResourceSourceSpinnakerApplication_basic(t *testing.T) {
 }
 
 func TestAccResourceSourceSpinnakerApplication_instancePort(t *testing.T) {
+	cassette := newTestAccCassette(t)
 	resourceName := "spinnaker_application.test"
-	rName := acctest.RandomWithPrefix("tf-acc-test")
-	rPort := acctest.RandIntRange(1, 1<<16)
+	rName := cassette.RandomName("tf-acc-test")
+	rPort := cassette.RandIntRange(1, 1<<16)
 	resource.ParallelTest(t, resource.TestCase{
-		PreCheck:     func() { testAccPreCheck(t) },
-		Providers:    testAccProviders,
-		CheckDestroy: testAccCheckSpinnakerApplicatioDestroy(t, resourceName),
+		PreCheck:     cassette.PreCheck,
+		Providers:    cassette.Providers(),
+		CheckDestroy: testAccCheckSpinnakerApplicatioDestroy(t, cassette.provider, resourceName),
 		Steps: []resource.TestStep{
 			{
 				Config: testAccSpinnakerApplication_instancePort(rName, rPort),
 				Check: resource.ComposeTestCheckFunc(
-					testAccCheckSpinnakerApplicationExists(resourceName),
+					testAccCheckSpinnakerApplicationExists(cassette.provider, resourceName),
 					resource.TestCheckResourceAttr(resourceName, "name", rName),
 					resource.TestCheckResourceAttr(resourceName, "email", "acceptance@test.com"),
 					resource.TestCheckResourceAttr(resourceName, "instance_port", strconv.Itoa(rPort)),
@@ -59,18 +63,19 @@ func TestAccResourceSourceSpinnakerApplication_instancePort(t *testing.T) {
 }
 
 func TestAccResourceSourceSpinnakerApplication_cloudProviders(t *testing.T) {
+	cassette := newTestAccCassette(t)
 	resourceName := "spinnaker_application.test"
-	rName := acctest.RandomWithPrefix("tf-acc-test")
+	rName := cassette.RandomName("tf-acc-test")
 	cloudProvider := "kubernetes"
 	resource.ParallelTest(t, resource.TestCase{
-		PreCheck:     func() { testAccPreCheck(t) },
-		Providers:    testAccProviders,
-		CheckDestroy: testAccCheckSpinnakerApplicatioDestroy(t, resourceName),
+		PreCheck:     cassette.PreCheck,
+		Providers:    cassette.Providers(),
+		CheckDestroy: testAccCheckSpinnakerApplicatioDestroy(t, cassette.provider, resourceName),
 		Steps: []resource.TestStep{
 			{
 				Config: testAccSpinnakerApplication_cloudProvider(rName, cloudProvider),
 				Check: resource.ComposeTestCheckFunc(
-					testAccCheckSpinnakerApplicationExists(resourceName),
+					testAccCheckSpinnakerApplicationExists(cassette.provider, resourceName),
 					resource.TestCheckResourceAttr(resourceName, "name", rName),
 					resource.TestCheckResourceAttr(resourceName, "email", "acceptance@test.com"),
 					resource.TestCheckResourceAttr(resourceName, "instance_port", strconv.Itoa(defaultInstancePort)),
@@ -81,7 +86,7 @@ func TestAccResourceSourceSpinnakerApplication_cloudProviders(t *testing.T) {
 	})
 }
 
-func testAccCheckSpinnakerApplicatioDestroy(t *testing.T, n string) resource.TestCheckFunc {
+func testAccCheckSpinnakerApplicatioDestroy(t *testing.T, p *schema.Provider, n string) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
 		rs, ok := s.RootModule().Resources[n]
 		if !ok {
@@ -93,12 +98,12 @@ func testAccCheckSpinnakerApplicatioDestroy(t *testing.T, n string) resource.Tes
 			return fmt.Errorf("No Application ID is set")
 		}
 
-		client := testAccProvider.Meta().(gateConfig).client
+		client := p.Meta().(gateConfig).client
 		app := &applicationRead{}
 
 		for retries := 1; retries <= 5; retries++ {
-			if err := api.GetApplication(client, appName, app); err != nil {
-				if strings.Contains(err.Error(), "not found") {
+			if err := client.GetApplication(appName, app); err != nil {
+				if errors.Is(err, api.ErrCodeNoSuchEntityException) {
 					return nil
 				}
 				return err
@@ -112,7 +117,7 @@ func testAccCheckSpinnakerApplicatioDestroy(t *testing.T, n string) resource.Tes
 	}
 }
 
-func testAccCheckSpinnakerApplicationExists(n string) resource.TestCheckFunc {
+func testAccCheckSpinnakerApplicationExists(p *schema.Provider, n string) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
 		rs, ok := s.RootModule().Resources[n]
 		if !ok {
@@ -122,17 +127,12 @@ func testAccCheckSpinnakerApplicationExists(n string) resource.TestCheckFunc {
 		if rs.Primary.ID == "" {
 			return fmt.Errorf("No Application ID is set")
 		}
-		client := testAccProvider.Meta().(gateConfig).client
+		client := p.Meta().(gateConfig).client
 		err := resource.Retry(1*time.Minute, func() *resource.RetryError {
-			_, resp, err := client.ApplicationControllerApi.GetApplicationUsingGET(client.Context, rs.Primary.ID, nil)
-			if resp != nil {
-				if resp.StatusCode == http.StatusNotFound {
+			if err := client.GetApplication(rs.Primary.ID, &applicationRead{}); err != nil {
+				if errors.Is(err, api.ErrCodeNoSuchEntityException) {
 					return resource.RetryableError(fmt.Errorf("application does not exit"))
-				} else if resp.StatusCode != http.StatusOK {
-					return resource.NonRetryableError(fmt.Errorf("encountered an error getting application, status code: %d", resp.StatusCode))
 				}
-			}
-			if err != nil {
 				return resource.NonRetryableError(err)
 			}
 			return nil
@@ -201,3 +201,84 @@ func TestValidateApplicationName(t *testing.T) {
 		}
 	}
 }
+
+func TestResourceSpinnakerApplication_unit(t *testing.T) {
+	resourceName := "spinnaker_application.test"
+	gate := fakegate.New()
+	testUnitTest(t, gate, testUnitCheckSpinnakerApplicationDeleted(gate, "tf-unit-test"),
+		resource.TestStep{
+			Config: testAccSpinnakerApplication_basic("tf-unit-test"),
+			Check: resource.ComposeTestCheckFunc(
+				resource.TestCheckResourceAttr(resourceName, "name", "tf-unit-test"),
+				resource.TestCheckResourceAttr(resourceName, "instance_port", strconv.Itoa(defaultInstancePort)),
+			),
+		},
+		resource.TestStep{
+			Config: testAccSpinnakerApplication_instancePort("tf-unit-test", 8080),
+			Check:  resource.TestCheckResourceAttr(resourceName, "instance_port", "8080"),
+		},
+	)
+}
+
+func testUnitCheckSpinnakerApplicationDeleted(gate *fakegate.Gate, appName string) resource.TestCheckFunc {
+	return testUnitCheckDeleted("application "+appName, func() error {
+		return gate.Client().GetApplication(appName, &applicationRead{})
+	})
+}
+
+func TestResourceSpinnakerApplicationCRUD(t *testing.T) {
+	gate := fakegate.New()
+	meta := testUnitMeta(gate)
+	r := resourceSpinnakerApplication()
+	d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"name":            "tf-unit-test",
+		"email":           "acceptance@test.com",
+		"instance_port":   8080,
+		"cloud_providers": []interface{}{"kubernetes"},
+		"run_as_user":     "team-svc",
+	})
+
+	if diags := r.CreateContext(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("failed: %v", diags)
+	}
+	if diags := r.ReadContext(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("failed: %v", diags)
+	}
+	if d.Id() != "tf-unit-test" || d.Get("instance_port").(int) != 8080 || d.Get("cloud_providers.0").(string) != "kubernetes" {
+		t.Fatalf("unexpected state after read: %v", d.State())
+	}
+
+	if diags := r.DeleteContext(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("failed: %v", diags)
+	}
+	if err := testUnitCheckSpinnakerApplicationDeleted(gate, "tf-unit-test")(nil); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	for _, task := range gate.Tasks() {
+		if task.User != "team-svc" {
+			t.Fatalf("expected task %s to run as team-svc, got %q", task.Type, task.User)
+		}
+	}
+}
+
+func TestResourceSpinnakerApplicationCreateTaskFailure(t *testing.T) {
+	gate := fakegate.New()
+	gate.FailTasks("createApplication", "Access denied to application")
+	r := resourceSpinnakerApplication()
+	d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"name":  "tf-unit-test",
+		"email": "acceptance@test.com",
+	})
+
+	diags := r.CreateContext(context.Background(), d, testUnitMeta(gate))
+	if !diags.HasError() {
+		t.Fatal("expected an error")
+	}
+	if !strings.Contains(diags[0].Detail, "Access denied to application") {
+		t.Fatalf("expected the exception of the task, got %v", diags)
+	}
+	if d.Id() != "" {
+		t.Fatalf("expected no application in the state, got %s", d.Id())
+	}
+}
@@ -0,0 +1,511 @@
+package spinnaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/retry"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	api "github.com/himanhsugusain/terraform-provider-spinnaker/spinnaker/api"
+)
+
+const (
+	defaultCanaryAnalysisStep = 60
+
+	canaryAnalysisResultPass     = "PASS"
+	canaryAnalysisResultMarginal = "MARGINAL"
+	canaryAnalysisResultFail     = "FAIL"
+)
+
+func resourceSpinnakerCanaryAnalysis() *schema.Resource {
+	return &schema.Resource{
+		Description: "Runs a standalone Kayenta canary analysis and waits for its result",
+		Schema: map[string]*schema.Schema{
+			"canary_config_id": {
+				Description: "ID of the canary config used to judge the analysis",
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+			},
+			"application": {
+				Description:  "Application the canary analysis is run for",
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validateSpinnakerApplicationName,
+			},
+			"metrics_account_name": {
+				Description: "Kayenta account to query the metrics from",
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"storage_account_name": {
+				Description: "Kayenta account to store the results in",
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"control_scope": {
+				Description: "Scope of the baseline metrics",
+				Type:        schema.TypeList,
+				Required:    true,
+				ForceNew:    true,
+				MaxItems:    1,
+				Elem: &schema.Resource{
+					Schema: getCanaryAnalysisScopeSchema(),
+				},
+			},
+			"experiment_scope": {
+				Description: "Scope of the canary metrics",
+				Type:        schema.TypeList,
+				Required:    true,
+				ForceNew:    true,
+				MaxItems:    1,
+				Elem: &schema.Resource{
+					Schema: getCanaryAnalysisScopeSchema(),
+				},
+			},
+			"start_time": {
+				Description:  "Start of the analysis in RFC 3339 format, defaults to the time the analysis is created",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.IsRFC3339Time,
+			},
+			"lifetime": {
+				Description:  "Duration of the analysis, e.g. \"1h\". Creating the analysis waits until start_time plus lifetime, the create timeout must be longer",
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validateSpinnakerDuration,
+			},
+			"interval": {
+				Description:  "Duration between judgements, defaults to the lifetime so that a single judgement is made",
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				ValidateFunc: validateSpinnakerDuration,
+			},
+			"step": {
+				Description:  "Resolution of the metrics in seconds",
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ForceNew:     true,
+				Default:      defaultCanaryAnalysisStep,
+				ValidateFunc: validation.IntAtLeast(1),
+			},
+			"score_thresholds": {
+				Description: "Score thresholds of the analysis, defaults to the score thresholds of the canary config classifier",
+				Type:        schema.TypeList,
+				Optional:    true,
+				Computed:    true,
+				ForceNew:    true,
+				MaxItems:    1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"marginal": {
+							Type:         schema.TypeFloat,
+							Description:  "Score under which the analysis fails",
+							Required:     true,
+							ForceNew:     true,
+							ValidateFunc: validation.FloatBetween(0, 100),
+						},
+						"pass": {
+							Type:         schema.TypeFloat,
+							Description:  "Score from which the analysis passes",
+							Required:     true,
+							ForceNew:     true,
+							ValidateFunc: validation.FloatBetween(0, 100),
+						},
+					},
+				},
+			},
+			"canary_execution_id": {
+				Description: "ID of the canary execution of the last judgement",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"score": {
+				Description: "Overall score of the analysis",
+				Type:        schema.TypeFloat,
+				Computed:    true,
+			},
+			"result": {
+				Description: "Result of the analysis judged against the score thresholds, one of PASS, MARGINAL or FAIL",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"classification": {
+				Description: "Classification of the overall score by the judge",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"interval_scores": {
+				Description: "Score of each judgement",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeFloat},
+			},
+			"metric_result": {
+				Description: "Classification of each metric",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:        schema.TypeString,
+							Description: "Name of the metric",
+							Computed:    true,
+						},
+						"classification": {
+							Type:        schema.TypeString,
+							Description: "Classification of the metric",
+							Computed:    true,
+						},
+						"classification_reason": {
+							Type:        schema.TypeString,
+							Description: "Reason of the classification",
+							Computed:    true,
+						},
+					},
+				},
+			},
+		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(60 * time.Minute),
+		},
+		CustomizeDiff: customizeDiffRequire(api.CapabilityCanary),
+		CreateContext: resourceSpinnakerCanaryAnalysisCreate,
+		ReadContext:   resourceSpinnakerCanaryAnalysisRead,
+		DeleteContext: resourceSpinnakerCanaryAnalysisDelete,
+	}
+}
+
+type canaryExecutionRead struct {
+	Complete  bool                   `json:"complete"`
+	Status    string                 `json:"status"`
+	Result    *canaryResult          `json:"result"`
+	Exception map[string]interface{} `json:"exception"`
+}
+
+type canaryResult struct {
+	JudgeResult *canaryJudgeResult `json:"judgeResult"`
+}
+
+type canaryJudgeResult struct {
+	Score   *canaryJudgeScore      `json:"score"`
+	Results []canaryAnalysisResult `json:"results"`
+}
+
+type canaryJudgeScore struct {
+	Score                float64 `json:"score"`
+	Classification       string  `json:"classification"`
+	ClassificationReason string  `json:"classificationReason"`
+}
+
+type canaryAnalysisResult struct {
+	Name                 string `json:"name"`
+	Classification       string `json:"classification"`
+	ClassificationReason string `json:"classificationReason"`
+}
+
+func resourceSpinnakerCanaryAnalysisCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	clientConfig := meta.(gateConfig)
+	if err := clientConfig.require(ctx, api.CapabilityCanary); err != nil {
+		return diag.FromErr(err)
+	}
+	client := clientConfig.client.WithContext(ctx)
+	configID := d.Get("canary_config_id").(string)
+
+	start := time.Now().UTC()
+	if v, ok := d.GetOk("start_time"); ok {
+		start, _ = time.Parse(time.RFC3339, v.(string))
+	}
+
+	lifetime, _ := time.ParseDuration(d.Get("lifetime").(string))
+	interval := lifetime
+	if v, ok := d.GetOk("interval"); ok {
+		interval, _ = time.ParseDuration(v.(string))
+	}
+	if interval > lifetime {
+		return diag.Errorf("interval %s must not be longer than lifetime %s", interval, lifetime)
+	}
+	// Fail right away rather than once the timeout expires while waiting for
+	// the end of the analysis
+	if timeout := d.Timeout(schema.TimeoutCreate); time.Until(start.Add(lifetime)) >= timeout {
+		return diag.Errorf("analysis ends at %s, after the create timeout of %s: set timeouts.create longer than lifetime %s", start.Add(lifetime).Format(time.RFC3339), timeout, lifetime)
+	}
+
+	marginal, pass, err := canaryAnalysisScoreThresholds(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	opts := api.CanaryExecutionOptions{
+		Application:        d.Get("application").(string),
+		MetricsAccountName: d.Get("metrics_account_name").(string),
+		StorageAccountName: d.Get("storage_account_name").(string),
+	}
+	control := expandCanaryAnalysisScope(d.Get("control_scope").([]interface{}))
+	experiment := expandCanaryAnalysisScope(d.Get("experiment_scope").([]interface{}))
+	control.Step = d.Get("step").(int)
+	experiment.Step = d.Get("step").(int)
+
+	var executionID string
+	var execution *canaryExecutionRead
+	scores := []float64{}
+	for end := start.Add(interval); ; end = end.Add(interval) {
+		if end.After(start.Add(lifetime)) {
+			end = start.Add(lifetime)
+		}
+
+		// Metrics of an interval can only be judged once the interval is over
+		if err := sleepUntil(ctx, end); err != nil {
+			return diag.FromErr(err)
+		}
+
+		control.Start, control.End = start, end
+		experiment.Start, experiment.End = start, end
+		request := api.NewCanaryExecutionRequest(control, experiment, marginal, pass)
+
+		executionID, err = client.InitiateCanary(configID, opts, request)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		execution, err = waitForCanaryExecution(ctx, d, meta, executionID)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		score := canaryExecutionScore(execution)
+		scores = append(scores, score)
+
+		// Same as the kayentaCanary stage, stop early once an interval
+		// does not reach the marginal threshold.
+		if !end.Before(start.Add(lifetime)) || score < marginal {
+			break
+		}
+	}
+
+	d.SetId(executionID)
+	d.Set("start_time", start.Format(time.RFC3339))
+	d.Set("score_thresholds", []interface{}{
+		map[string]interface{}{
+			"marginal": marginal,
+			"pass":     pass,
+		},
+	})
+	d.Set("interval_scores", scores)
+
+	return setCanaryAnalysisResult(d, execution)
+}
+
+func resourceSpinnakerCanaryAnalysisRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	clientConfig := meta.(gateConfig)
+	client := clientConfig.client.WithContext(ctx)
+
+	execution := &canaryExecutionRead{}
+	if err := client.GetCanaryResult(d.Id(), d.Get("storage_account_name").(string), execution); err != nil {
+		// A finished analysis never changes, keep the recorded result
+		// when the storage account has already purged it.
+		if errors.Is(err, api.ErrCodeNoSuchEntityException) {
+			tflog.Warn(ctx, "Canary execution not found, keeping the recorded result", map[string]interface{}{"canary_execution_id": d.Id()})
+			return nil
+		}
+		return diag.FromErr(err)
+	}
+
+	return setCanaryAnalysisResult(d, execution)
+}
+
+func resourceSpinnakerCanaryAnalysisDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	// Canary results can not be deleted through Gate, just forget about it.
+	d.SetId("")
+	return nil
+}
+
+func waitForCanaryExecution(ctx context.Context, d *schema.ResourceData, meta interface{}, executionID string) (*canaryExecutionRead, error) {
+	clientConfig := meta.(gateConfig)
+	client := clientConfig.client.WithContext(ctx)
+	storageAccountName := d.Get("storage_account_name").(string)
+
+	execution := &canaryExecutionRead{}
+	err := retry.RetryContext(ctx, d.Timeout(schema.TimeoutCreate), func() *retry.RetryError {
+		execution = &canaryExecutionRead{}
+		if err := client.GetCanaryResult(executionID, storageAccountName, execution); err != nil {
+			if errors.Is(err, api.ErrCodeNoSuchEntityException) {
+				return retry.RetryableError(err)
+			}
+			return retry.NonRetryableError(err)
+		}
+
+		if !execution.Complete {
+			return retry.RetryableError(fmt.Errorf("canary execution %s is %s", executionID, execution.Status))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if execution.Result == nil || execution.Result.JudgeResult == nil || execution.Result.JudgeResult.Score == nil {
+		return nil, fmt.Errorf("canary execution %s finished with status %s and no result: %v", executionID, execution.Status, execution.Exception)
+	}
+
+	return execution, nil
+}
+
+func setCanaryAnalysisResult(d *schema.ResourceData, execution *canaryExecutionRead) diag.Diagnostics {
+	if execution.Result == nil || execution.Result.JudgeResult == nil || execution.Result.JudgeResult.Score == nil {
+		return nil
+	}
+
+	judgeResult := execution.Result.JudgeResult
+	score := judgeResult.Score.Score
+	d.Set("canary_execution_id", d.Id())
+	d.Set("score", score)
+	d.Set("classification", judgeResult.Score.Classification)
+
+	var marginal, pass float64
+	if vs := d.Get("score_thresholds").([]interface{}); len(vs) == 1 && vs[0] != nil {
+		v := vs[0].(map[string]interface{})
+		marginal, pass = v["marginal"].(float64), v["pass"].(float64)
+	}
+	d.Set("result", judgeCanaryScore(score, marginal, pass))
+
+	metricResults := make([]interface{}, len(judgeResult.Results))
+	for i, r := range judgeResult.Results {
+		metricResults[i] = map[string]interface{}{
+			"name":                  r.Name,
+			"classification":        r.Classification,
+			"classification_reason": r.ClassificationReason,
+		}
+	}
+	if err := d.Set("metric_result", metricResults); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
+
+// canaryAnalysisScoreThresholds returns the configured thresholds, falling back
+// to the score thresholds of the canary config classifier
+func canaryAnalysisScoreThresholds(ctx context.Context, d *schema.ResourceData, meta interface{}) (float64, float64, error) {
+	if vs := d.Get("score_thresholds").([]interface{}); len(vs) == 1 && vs[0] != nil {
+		v := vs[0].(map[string]interface{})
+		marginal, pass := v["marginal"].(float64), v["pass"].(float64)
+		return marginal, pass, api.ValidateCanaryConfigScoreThresholds(marginal, pass)
+	}
+
+	clientConfig := meta.(gateConfig)
+	client := clientConfig.client.WithContext(ctx)
+	configID := d.Get("canary_config_id").(string)
+
+	config := &canaryConfigRead{}
+	if err := client.GetCanaryConfig(configID, config); err != nil {
+		return 0, 0, err
+	}
+
+	if config.Classifier == nil || config.Classifier.ScoreThresholds == nil {
+		return 0, 0, fmt.Errorf("canary config %s has no score thresholds, set score_thresholds", configID)
+	}
+
+	t := config.Classifier.ScoreThresholds
+	return t.Marginal, t.Pass, nil
+}
+
+func canaryExecutionScore(execution *canaryExecutionRead) float64 {
+	return execution.Result.JudgeResult.Score.Score
+}
+
+func judgeCanaryScore(score, marginal, pass float64) string {
+	switch {
+	case score >= pass:
+		return canaryAnalysisResultPass
+	case score >= marginal:
+		return canaryAnalysisResultMarginal
+	default:
+		return canaryAnalysisResultFail
+	}
+}
+
+func expandCanaryAnalysisScope(vs []interface{}) api.CanaryScope {
+	v := vs[0].(map[string]interface{})
+	scope := api.CanaryScope{
+		Scope:               v["scope"].(string),
+		Location:            v["location"].(string),
+		ExtendedScopeParams: map[string]string{},
+	}
+
+	for k, param := range v["extended_scope_params"].(map[string]interface{}) {
+		scope.ExtendedScopeParams[k] = param.(string)
+	}
+
+	return scope
+}
+
+func sleepUntil(ctx context.Context, t time.Time) error {
+	wait := time.Until(t)
+	if wait <= 0 {
+		return nil
+	}
+
+	tflog.Info(ctx, "Waiting for the canary analysis interval to end", map[string]interface{}{"wait": wait.Round(time.Second).String()})
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func getCanaryAnalysisScopeSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"scope": {
+			Type:        schema.TypeString,
+			Description: "Scope of the metrics, e.g. the server group name",
+			Required:    true,
+			ForceNew:    true,
+		},
+		"location": {
+			Type:        schema.TypeString,
+			Description: "Location of the metrics, e.g. the region",
+			Optional:    true,
+			ForceNew:    true,
+		},
+		"extended_scope_params": {
+			Type:        schema.TypeMap,
+			Description: "Additional parameters passed to the metrics service",
+			Optional:    true,
+			ForceNew:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+		},
+	}
+}
+
+func validateSpinnakerDuration(v interface{}, k string) (ws []string, errors []error) {
+	value := v.(string)
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		errors = append(errors, fmt.Errorf("%q is not a valid duration: %s", k, err))
+		return
+	}
+
+	if duration <= 0 {
+		errors = append(errors, fmt.Errorf("%q must be a positive duration, got %s", k, value))
+	}
+	return
+}
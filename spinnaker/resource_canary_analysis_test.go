@@ -0,0 +1,148 @@
+package spinnaker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/himanhsugusain/terraform-provider-spinnaker/spinnaker/fakegate"
+)
+
+func TestAccResourceSpinnakerCanaryAnalysis_basic(t *testing.T) {
+	resourceName := "spinnaker_canary_analysis.test"
+	rName := acctest.RandomWithPrefix("tf-acc-test")
+	start := time.Now().UTC().Add(-1 * time.Hour).Format(time.RFC3339)
+	resource.ParallelTest(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSpinnakerCanaryAnalysis_basic(rName, start),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrSet(resourceName, "canary_execution_id"),
+					resource.TestCheckResourceAttrSet(resourceName, "score"),
+					resource.TestCheckResourceAttrSet(resourceName, "result"),
+					resource.TestCheckResourceAttr(resourceName, "start_time", start),
+					resource.TestCheckResourceAttr(resourceName, "interval_scores.#", "2"),
+					resource.TestCheckResourceAttr(resourceName, "score_thresholds.0.marginal", "75"),
+					resource.TestCheckResourceAttr(resourceName, "score_thresholds.0.pass", "95"),
+				),
+			},
+		},
+	})
+}
+
+func TestResourceSpinnakerCanaryAnalysisCreateTimeout(t *testing.T) {
+	tcs := map[string]struct {
+		start    time.Time
+		lifetime string
+		timedOut bool
+	}{
+		"ends after timeout":  {time.Now().UTC(), "2h", true},
+		"ends within timeout": {time.Now().UTC(), "1m", false},
+		"ended in the past":   {time.Now().UTC().Add(-3 * time.Hour), "2h", false},
+	}
+
+	for n, tc := range tcs {
+		tc := tc
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			r := resourceSpinnakerCanaryAnalysis()
+			d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+				"canary_config_id": "missing",
+				"application":      "tf-unit-test",
+				"start_time":       tc.start.Format(time.RFC3339),
+				"lifetime":         tc.lifetime,
+			})
+
+			// The canary config is missing, an analysis within the timeout
+			// fails on it rather than on the timeout
+			diags := r.CreateContext(context.Background(), d, testUnitMeta(fakegate.New()))
+			if !diags.HasError() {
+				t.Fatalf("expected an error")
+			}
+			if got := strings.Contains(diags[0].Summary, "timeouts.create"); got != tc.timedOut {
+				t.Fatalf("expected timed out %t, got %s", tc.timedOut, diags[0].Summary)
+			}
+		})
+	}
+}
+
+func TestJudgeCanaryScore(t *testing.T) {
+	tcs := map[string]struct {
+		score    float64
+		expected string
+	}{
+		"pass":             {100, canaryAnalysisResultPass},
+		"pass on boundary": {95, canaryAnalysisResultPass},
+		"marginal":         {80, canaryAnalysisResultMarginal},
+		"marginal on edge": {75, canaryAnalysisResultMarginal},
+		"fail":             {74.9, canaryAnalysisResultFail},
+	}
+
+	for n, tc := range tcs {
+		tc := tc
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			if got := judgeCanaryScore(tc.score, 75, 95); got != tc.expected {
+				t.Fatalf("expected %s, got %s", tc.expected, got)
+			}
+		})
+	}
+}
+
+func testAccSpinnakerCanaryAnalysis_basic(rName, start string) string {
+	return fmt.Sprintf(`
+resource "spinnaker_canary_config" "test" {
+  name         = "%s"
+  description  = "%s"
+  applications = ["keke-test"]
+
+  metric {
+    name   = "CPU"
+    groups = ["Group 1"]
+
+    query {
+      type          = "stackdriver"
+      service_type  = "stackdriver"
+      resource_type = "k8s_node"
+      metric_type   = "kubernetes.io/anthos/gkeconnect_dialer_connection_attempts_total"
+    }
+  }
+
+  classifier {
+    group_weights = {
+      "Group 1" = 100
+    }
+
+    score_thresholds {
+      marginal = 75
+      pass     = 95
+    }
+  }
+}
+
+resource "spinnaker_canary_analysis" "test" {
+  canary_config_id = spinnaker_canary_config.test.id
+  application      = "keke-test"
+  start_time       = "%s"
+  lifetime         = "30m"
+  interval         = "15m"
+
+  control_scope {
+    scope    = "keke-test-baseline"
+    location = "us-central1"
+  }
+
+  experiment_scope {
+    scope    = "keke-test-canary"
+    location = "us-central1"
+  }
+}
+`, rName, testDesc, start)
+}
@@ -2,14 +2,19 @@ package spinnaker
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"regexp"
+	"strconv"
 
 	api "github.com/himanhsugusain/terraform-provider-spinnaker/spinnaker/api"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/mitchellh/mapstructure"
 )
 
 func resourceSpinnakerCanaryConfig() *schema.Resource {
@@ -29,30 +34,45 @@ func resourceSpinnakerCanaryConfig() *schema.Resource {
 				Default:     "",
 			},
 			"applications": {
-				Description: "List of the application which the canary config belongs",
-				Type:        schema.TypeList,
-				MaxItems:    1,
-				Required:    true,
-				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Set of the application which the canary config belongs, defaults to the default_application of the provider",
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Computed:    true,
+				MinItems:    1,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validateSpinnakerApplicationName,
+				},
 			},
 			"metric": {
-				Description: "Metric to analyze",
-				Type:        schema.TypeList,
-				Required:    true,
+				Description:  "Metric to analyze",
+				Type:         schema.TypeList,
+				Optional:     true,
+				ExactlyOneOf: []string{"metric", "config_json"},
 				Elem: &schema.Resource{
 					Schema: getCanaryConfigMetricSchema(),
 				},
 			},
 			"classifier": {
-				Type:        schema.TypeList,
-				Description: "Classification configuration",
-				MaxItems:    1,
-				Required:    true,
+				Type:         schema.TypeList,
+				Description:  "Classification configuration",
+				MaxItems:     1,
+				Optional:     true,
+				ExactlyOneOf: []string{"classifier", "config_json"},
 				Elem: &schema.Resource{
 					Schema: getCanaryConfigMetricClassifier(),
 				},
 			},
+			"config_json": {
+				Description:      "Kayenta canary config document in JSON, used instead of the metric and classifier blocks",
+				Type:             schema.TypeString,
+				Optional:         true,
+				ConflictsWith:    []string{"metric", "classifier"},
+				ValidateFunc:     validation.StringIsJSON,
+				DiffSuppressFunc: suppressEquivalentCanaryConfigDiffs,
+			},
 		},
+		CustomizeDiff: resourceSpinnakerCanaryConfigCustomizeDiff,
 		CreateContext: resourceSpinnakerCanaryConfigCreate,
 		ReadContext:   resourceSpinnakerCanaryConfigRead,
 		UpdateContext: resourceSpinnakerCanaryConfigUpdate,
@@ -64,43 +84,52 @@ func resourceSpinnakerCanaryConfig() *schema.Resource {
 }
 
 type canaryConfigRead struct {
-	id            string      `json:"id"`
-	name          string      `json:"name"`
-	description   string      `json:"description"`
-	configVersion string      `json:"configVersion"`
-	applications  []string    `json:"applications"`
-	judge         *judge      `json:"judge"`
-	metrics       []metric    `json:"metrics"`
-	templates     *templates  `json:"templates"`
-	classifier    *classifier `json:"classifier"`
+	ID            string      `json:"id"`
+	Name          string      `json:"name"`
+	Description   string      `json:"description"`
+	ConfigVersion string      `json:"configVersion"`
+	Applications  []string    `json:"applications"`
+	Classifier    *classifier `json:"classifier"`
 }
 
-type judge struct {
-	name                string              `json:"name"`
-	judgeConfigurations judgeConfigurations `json:"judgeConfigurations"`
-}
-
-type judgeConfigurations struct{}
-
+// metric is a metric of a canary config saved from metric blocks, Kayenta
+// documents of config_json may have queries of any shape
 type metric struct {
-	name string
+	Name   string      `json:"name"`
+	Query  metricQuery `json:"query"`
+	Groups []string    `json:"groups"`
 }
 
-type templates struct{}
+type metricQuery struct {
+	Type             string `json:"type"`
+	ServiceType      string `json:"serviceType"`
+	PerSeriesAligner string `json:"perSeriesAligner"`
+	ResourceType     string `json:"resourceType"`
+	MetricType       string `json:"metricType"`
+}
 
 type classifier struct {
-	groupWeights map[string]int `json:"groupWeights"`
+	GroupWeights    map[string]float64 `json:"groupWeights"`
+	ScoreThresholds *scoreThresholds   `json:"scoreThresholds"`
+}
+
+type scoreThresholds struct {
+	Marginal float64 `json:"marginal"`
+	Pass     float64 `json:"pass"`
 }
 
 func resourceSpinnakerCanaryConfigCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	clientConfig := meta.(gateConfig)
-	client := clientConfig.client
+	if err := clientConfig.require(ctx, api.CapabilityCanary); err != nil {
+		return diag.FromErr(err)
+	}
+	client := clientConfig.client.WithContext(ctx)
 	config, err := api.NewCanaryConfig(d)
 	if err != nil {
 		return diag.FromErr(err)
 	}
 
-	id, err := api.CreateCanaryConfig(client, config)
+	id, err := client.CreateCanaryConfig(config)
 	if err != nil {
 		return diag.FromErr(err)
 	}
@@ -110,42 +139,72 @@ func resourceSpinnakerCanaryConfigCreate(ctx context.Context, d *schema.Resource
 }
 
 func resourceSpinnakerCanaryConfigRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	_, jsonMode := d.GetOk("config_json")
+	return readCanaryConfig(ctx, d, meta, jsonMode)
+}
+
+// readCanaryConfig reads the canary config into config_json in JSON mode, or
+// into the metric and classifier blocks
+func readCanaryConfig(ctx context.Context, d *schema.ResourceData, meta interface{}, jsonMode bool) diag.Diagnostics {
 	clientConfig := meta.(gateConfig)
-	client := clientConfig.client
+	client := clientConfig.client.WithContext(ctx)
 	id := d.Id()
 
+	doc := map[string]interface{}{}
+	if err := client.GetCanaryConfig(id, &doc); err != nil {
+		return diag.FromErr(err)
+	}
+
 	config := &canaryConfigRead{}
-	if err := api.GetCanaryConfig(client, id, config); err != nil {
+	if err := mapstructure.Decode(doc, config); err != nil {
 		return diag.FromErr(err)
 	}
 
-	if v := config.name; v != "" {
-		d.Set("name", v)
+	if jsonMode {
+		configJSON, err := api.EncodeCanaryConfigJSON(doc)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		if err := d.Set("config_json", configJSON); err != nil {
+			return diag.FromErr(err)
+		}
 	}
 
-	if v := config.description; v != "" {
-		d.Set("description", v)
+	if v := config.Name; v != "" {
+		if err := d.Set("name", v); err != nil {
+			return diag.FromErr(err)
+		}
 	}
 
-	if v := config.applications; v != nil {
-		d.Set("applications", v)
+	if v := config.Description; v != "" {
+		if err := d.Set("description", v); err != nil {
+			return diag.FromErr(err)
+		}
 	}
 
-	if v := config.judge; v != nil {
-		d.Set("judge", v)
+	if v := config.Applications; v != nil {
+		if err := d.Set("applications", v); err != nil {
+			return diag.FromErr(err)
+		}
 	}
 
-	if v := config.metrics; v != nil {
-		tfMetrics := buildTerraformMetrics(v)
-		d.Set("metrics", tfMetrics)
+	if jsonMode {
+		return nil
 	}
 
-	if v := config.templates; v != nil {
-		d.Set("template", v)
+	var metrics []metric
+	if err := mapstructure.Decode(doc["metrics"], &metrics); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("metric", buildTerraformMetrics(metrics, d.Get("metric").([]interface{}))); err != nil {
+		return diag.FromErr(err)
 	}
 
-	if v := config.classifier; v != nil {
-		d.Set("classifier", v)
+	if v := config.Classifier; v != nil {
+		if err := d.Set("classifier", buildTerraformClassifier(v)); err != nil {
+			return diag.FromErr(err)
+		}
 	}
 
 	return nil
@@ -153,14 +212,17 @@ func resourceSpinnakerCanaryConfigRead(ctx context.Context, d *schema.ResourceDa
 
 func resourceSpinnakerCanaryConfigUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	clientConfig := meta.(gateConfig)
-	client := clientConfig.client
+	if err := clientConfig.require(ctx, api.CapabilityCanary); err != nil {
+		return diag.FromErr(err)
+	}
+	client := clientConfig.client.WithContext(ctx)
 	config, err := api.NewCanaryConfig(d)
 	if err != nil {
 		return diag.FromErr(err)
 	}
 
 	id := d.Id()
-	if err := api.UpdateCanaryConfig(client, id, config); err != nil {
+	if err := client.UpdateCanaryConfig(id, config); err != nil {
 		return diag.FromErr(err)
 	}
 
@@ -170,9 +232,9 @@ func resourceSpinnakerCanaryConfigUpdate(ctx context.Context, d *schema.Resource
 func resourceSpinnakerCanaryConfigDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	clientConfig := meta.(gateConfig)
 	var diags diag.Diagnostics
-	client := clientConfig.client
+	client := clientConfig.client.WithContext(ctx)
 	id := d.Id()
-	if err := api.DeleteCanaryConfig(client, id); err != nil {
+	if err := client.DeleteCanaryConfig(id); err != nil {
 		return diag.FromErr(err)
 	}
 
@@ -180,13 +242,116 @@ func resourceSpinnakerCanaryConfigDelete(ctx context.Context, d *schema.Resource
 	return diags
 }
 
+func resourceSpinnakerCanaryConfigCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if err := customizeDiffRequire(api.CapabilityCanary)(ctx, d, meta); err != nil {
+		return err
+	}
+
+	if err := customizeDiffDefault("applications", "default_application")(ctx, d, meta); err != nil {
+		return err
+	}
+
+	if err := validateSpinnakerCanaryConfigApplicationsExist(ctx, d, meta); err != nil {
+		return err
+	}
+
+	if v, ok := d.GetOk("config_json"); ok {
+		if !d.NewValueKnown("config_json") {
+			return nil
+		}
+
+		cfg := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(v.(string)), &cfg); err != nil {
+			return fmt.Errorf("could not decode config_json: %s", err)
+		}
+
+		return api.ValidateCanaryConfigDocument(cfg)
+	}
+
+	// Values derived from other resources are only known at apply time,
+	// NewCanaryConfig validates them again before saving.
+	if !d.NewValueKnown("classifier") || !d.NewValueKnown("metric") {
+		return nil
+	}
+
+	classifiers := d.Get("classifier").([]interface{})
+	if len(classifiers) != 1 || classifiers[0] == nil {
+		return nil
+	}
+
+	c := classifiers[0].(map[string]interface{})
+	if vs := c["score_thresholds"].([]interface{}); len(vs) == 1 && vs[0] != nil {
+		v := vs[0].(map[string]interface{})
+		if err := api.ValidateCanaryConfigScoreThresholds(v["marginal"].(float64), v["pass"].(float64)); err != nil {
+			return err
+		}
+	}
+
+	groupWeights, err := api.ParseCanaryConfigGroupWeights(c["group_weights"].(map[string]interface{}))
+	if err != nil {
+		return err
+	}
+
+	groups := []string{}
+	for _, m := range d.Get("metric").([]interface{}) {
+		if m == nil {
+			continue
+		}
+		for _, group := range m.(map[string]interface{})["groups"].([]interface{}) {
+			groups = append(groups, group.(string))
+		}
+	}
+
+	return api.ValidateCanaryConfigClassifier(groupWeights, groups)
+}
+
+// resourceSpinnakerCanaryConfigImport imports the canary config as config_json,
+// which holds the whole Kayenta document
 func resourceSpinnakerCanaryConfigImport(ctx context.Context, d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
-	if diags := resourceSpinnakerCanaryConfigRead(ctx, d, meta); diags.HasError() {
+	if diags := readCanaryConfig(ctx, d, meta, true); diags.HasError() {
 		return nil, fmt.Errorf("failed to read canary config")
 	}
 	return []*schema.ResourceData{d}, nil
 }
 
+// validateSpinnakerCanaryConfigApplicationsExist checks during plan that every
+// application of the canary config exists in Spinnaker
+func validateSpinnakerCanaryConfigApplicationsExist(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	// Applications created in the same apply are unknown until then
+	if meta == nil || !d.NewValueKnown("applications") || !d.HasChange("applications") {
+		return nil
+	}
+
+	clientConfig := meta.(gateConfig)
+	client := clientConfig.client.WithContext(ctx)
+	for _, v := range d.Get("applications").(*schema.Set).List() {
+		appName := v.(string)
+		app := &applicationRead{}
+		if err := client.GetApplication(appName, app); err != nil {
+			if errors.Is(err, api.ErrCodeNoSuchEntityException) {
+				return fmt.Errorf("application %q of the canary config does not exist", appName)
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+func suppressEquivalentCanaryConfigDiffs(k, old, new string, d *schema.ResourceData) bool {
+	normalizedOld, err := api.NormalizeCanaryConfigJSON(old)
+	if err != nil {
+		return false
+	}
+
+	normalizedNew, err := api.NormalizeCanaryConfigJSON(new)
+	if err != nil {
+		return false
+	}
+
+	return normalizedOld == normalizedNew
+}
+
 func getCanaryConfigMetricSchema() map[string]*schema.Schema {
 	return map[string]*schema.Schema{
 		"name": {
@@ -371,21 +536,93 @@ func getCanaryConfigMetricQueryAnalysisConfigurationCanary() map[string]*schema.
 func getCanaryConfigMetricClassifier() map[string]*schema.Schema {
 	return map[string]*schema.Schema{
 		"group_weights": {
-			Type:        schema.TypeMap,
-			Description: "Weight for each groups",
-			Required:    true,
+			Type:         schema.TypeMap,
+			Description:  "Weight for each groups, the weights must total 100",
+			Required:     true,
+			Elem:         &schema.Schema{Type: schema.TypeString},
+			ValidateFunc: validateSpinnakerCanaryConfigGroupWeights,
+		},
+		"score_thresholds": {
+			Type:        schema.TypeList,
+			Description: "Score thresholds used by canary analysis stages to judge the result",
+			Optional:    true,
+			MaxItems:    1,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"marginal": {
+						Type:         schema.TypeFloat,
+						Description:  "Score under which the canary analysis fails",
+						Required:     true,
+						ValidateFunc: validation.FloatBetween(0, 100),
+					},
+					"pass": {
+						Type:         schema.TypeFloat,
+						Description:  "Score from which the canary analysis passes",
+						Required:     true,
+						ValidateFunc: validation.FloatBetween(0, 100),
+					},
+				},
+			},
 		},
 	}
 }
 
-func buildTerraformMetrics(metrics []metric) *map[string]interface{} {
-	res := map[string]interface{}{}
-	for _, metric := range metrics {
-		r := map[string]interface{}{}
-		res[metric.name] = r
+// buildTerraformMetrics returns the metric blocks of the metrics of a canary
+// config. The attributes which are not saved to Kayenta, such as
+// analysis_configurations, are kept from the metric at the same index in prior.
+func buildTerraformMetrics(metrics []metric, prior []interface{}) []interface{} {
+	res := make([]interface{}, 0, len(metrics))
+	for i, m := range metrics {
+		priorMetric := map[string]interface{}{}
+		if i < len(prior) && prior[i] != nil {
+			priorMetric = prior[i].(map[string]interface{})
+		}
+		priorQuery := map[string]interface{}{}
+		if qs, ok := priorMetric["query"].([]interface{}); ok && len(qs) == 1 && qs[0] != nil {
+			priorQuery = qs[0].(map[string]interface{})
+		}
+
+		query := map[string]interface{}{
+			"type":                 m.Query.Type,
+			"service_type":         m.Query.ServiceType,
+			"per_series_aligner":   m.Query.PerSeriesAligner,
+			"resource_type":        m.Query.ResourceType,
+			"metric_type":          m.Query.MetricType,
+			"cross_series_reducer": priorQuery["cross_series_reducer"],
+			"group_by_fields":      priorQuery["group_by_fields"],
+		}
+
+		res = append(res, map[string]interface{}{
+			"name":                    m.Name,
+			"query":                   []interface{}{query},
+			"groups":                  m.Groups,
+			"analysis_configurations": priorMetric["analysis_configurations"],
+		})
 	}
 
-	return &res
+	return res
+}
+
+func buildTerraformClassifier(c *classifier) []interface{} {
+	groupWeights := map[string]interface{}{}
+	for group, weight := range c.GroupWeights {
+		groupWeights[group] = strconv.FormatFloat(weight, 'f', -1, 64)
+	}
+
+	res := map[string]interface{}{
+		"group_weights": groupWeights,
+	}
+
+	if v := c.ScoreThresholds; v != nil {
+		res["score_thresholds"] = []interface{}{
+			map[string]interface{}{
+				"marginal": v.Marginal,
+				"pass":     v.Pass,
+			},
+		}
+	}
+
+	return []interface{}{res}
 }
 
 func validateSpinnakerCanaryConfigName(v interface{}, k string) (ws []string, errors []error) {
@@ -396,6 +633,14 @@ func validateSpinnakerCanaryConfigName(v interface{}, k string) (ws []string, er
 	return
 }
 
+func validateSpinnakerCanaryConfigGroupWeights(v interface{}, k string) (ws []string, errors []error) {
+	input := v.(map[string]interface{})
+	if _, err := api.ParseCanaryConfigGroupWeights(input); err != nil {
+		errors = append(errors, err)
+	}
+	return
+}
+
 func validateSpinnakerCanaryConfigQueryAligner(v interface{}, k string) (ws []string, errors []error) {
 	value := v.(string)
 	for _, allowedAligner := range api.AllowedAligners {
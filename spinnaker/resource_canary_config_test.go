@@ -1,18 +1,22 @@
 package spinnaker
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"net/http"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 
 	"github.com/himanhsugusain/terraform-provider-spinnaker/spinnaker/api"
+	"github.com/himanhsugusain/terraform-provider-spinnaker/spinnaker/fakegate"
 )
 
 const (
@@ -40,6 +44,51 @@ func TestAccResourceSourceSpinnakerCanaryConfig_basic(t *testing.T) {
 					resource.TestCheckResourceAttr(resourceName, "metric.query.service_type", "stackdriver"),
 					resource.TestCheckResourceAttr(resourceName, "metric.query.resource_type", "k8s_node"),
 					resource.TestCheckResourceAttr(resourceName, "metric.query.metric_type", "kubernetes.io/anthos/gkeconnect_dialer_connection_attempts_total"),
+					resource.TestCheckResourceAttr(resourceName, "classifier.0.group_weights.Group 1", "100"),
+					resource.TestCheckResourceAttr(resourceName, "classifier.0.score_thresholds.0.marginal", "75"),
+					resource.TestCheckResourceAttr(resourceName, "classifier.0.score_thresholds.0.pass", "95"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccResourceSourceSpinnakerCanaryConfig_multipleApplications(t *testing.T) {
+	resourceName := "spinnaker_canary_config.test"
+	rName := acctest.RandomWithPrefix("tf-acc-test")
+	resource.ParallelTest(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckSpinnakerCanaryConfigConfigDestroy(resourceName),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSpinnakerCanaryConfig_multipleApplications(rName),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckSpinnakerCanaryConfigExists(resourceName),
+					resource.TestCheckResourceAttr(resourceName, "applications.#", "2"),
+					resource.TestCheckTypeSetElemAttr(resourceName, "applications.*", rName+"-a"),
+					resource.TestCheckTypeSetElemAttr(resourceName, "applications.*", rName+"-b"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccResourceSourceSpinnakerCanaryConfig_configJSON(t *testing.T) {
+	resourceName := "spinnaker_canary_config.test"
+	rName := acctest.RandomWithPrefix("tf-acc-test")
+	resource.ParallelTest(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckSpinnakerCanaryConfigConfigDestroy(resourceName),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSpinnakerCanaryConfig_configJSON(rName),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckSpinnakerCanaryConfigExists(resourceName),
+					resource.TestCheckResourceAttr(resourceName, "name", rName),
+					resource.TestCheckResourceAttr(resourceName, "metric.#", "0"),
+					resource.TestCheckResourceAttrPair(resourceName, "config_json", "data.spinnaker_canary_config_document.test", "config_json"),
 				),
 			},
 		},
@@ -63,7 +112,7 @@ func testAccCheckSpinnakerCanaryConfigConfigDestroy(n string) resource.TestCheck
 
 		retry := 5
 		for {
-			if err := api.GetCanaryConfig(client, id, cfg); err != nil {
+			if err := client.GetCanaryConfig(id, cfg); err != nil {
 				if strings.Contains(err.Error(), "not found") {
 					return nil
 				}
@@ -102,15 +151,10 @@ func testAccCheckSpinnakerCanaryConfigExists(n string) resource.TestCheckFunc {
 		}
 		client := testAccProvider.Meta().(gateConfig).client
 		err := resource.Retry(1*time.Minute, func() *resource.RetryError {
-			_, resp, err := client.V2CanaryConfigControllerApi.GetCanaryConfigUsingGET(client.Context, rs.Primary.ID, nil)
-			if resp != nil {
-				if resp != nil && resp.StatusCode == http.StatusNotFound {
+			if err := client.GetCanaryConfig(rs.Primary.ID, &canaryConfigRead{}); err != nil {
+				if errors.Is(err, api.ErrCodeNoSuchEntityException) {
 					return resource.RetryableError(fmt.Errorf("canary config does not exit"))
-				} else if resp.StatusCode != http.StatusOK {
-					return resource.NonRetryableError(fmt.Errorf("encountered an error getting canary config, status code: %d", resp.StatusCode))
 				}
-			}
-			if err != nil {
 				return resource.NonRetryableError(err)
 			}
 			return nil
@@ -141,6 +185,89 @@ resource "spinnaker_canary_config" "test" {
     }
   }
 
+  classifier {
+    group_weights = {
+      "Group 1" = 100
+    }
+
+    score_thresholds {
+      marginal = 75
+      pass     = 95
+    }
+  }
+}
+`, rName, testDesc)
+}
+
+func testAccSpinnakerCanaryConfig_configJSON(rName string) string {
+	return fmt.Sprintf(`
+resource "spinnaker_canary_config" "test" {
+  name         = "%s"
+  description  = "%s"
+  applications = ["keke-test"]
+
+  config_json = jsonencode({
+    configVersion = "1"
+    judge = {
+      name                = "NetflixACAJudge-v1.0"
+      judgeConfigurations = {}
+    }
+    templates = {}
+    metrics = [{
+      name      = "CPU"
+      groups    = ["Group 1"]
+      scopeName = "default"
+      analysisConfigurations = {}
+      query = {
+        type         = "stackdriver"
+        serviceType  = "stackdriver"
+        resourceType = "k8s_node"
+        metricType   = "kubernetes.io/anthos/gkeconnect_dialer_connection_attempts_total"
+      }
+    }]
+    classifier = {
+      groupWeights = {
+        "Group 1" = 100
+      }
+    }
+  })
+}
+
+data "spinnaker_canary_config_document" "test" {
+  canary_config_id = spinnaker_canary_config.test.id
+}
+`, rName, testDesc)
+}
+
+func testAccSpinnakerCanaryConfig_multipleApplications(rName string) string {
+	return fmt.Sprintf(`
+resource "spinnaker_application" "a" {
+  name  = "%[1]s-a"
+  email = "acceptance@test.com"
+}
+
+resource "spinnaker_application" "b" {
+  name  = "%[1]s-b"
+  email = "acceptance@test.com"
+}
+
+resource "spinnaker_canary_config" "test" {
+  name         = "%[1]s"
+  description  = "%[2]s"
+  applications = [spinnaker_application.b.id, spinnaker_application.a.id]
+
+  metric {
+    name   = "CPU"
+    groups = ["Group 1"]
+
+    query {
+      type          = "stackdriver"
+      service_type  = "stackdriver"
+      resource_type = "k8s_node"
+      metric_type   = "kubernetes.io/anthos/gkeconnect_dialer_connection_attempts_total"
+    }
+  }
+
   classifier {
     group_weights = {
       "Group 1" = 100
@@ -149,3 +276,199 @@ resource "spinnaker_canary_config" "test" {
 }
 `, rName, testDesc)
 }
+
+func TestResourceSpinnakerCanaryConfig_unit(t *testing.T) {
+	resourceName := "spinnaker_canary_config.test"
+	gate := fakegate.New()
+	testUnitTest(t, gate, nil,
+		resource.TestStep{
+			Config: testAccSpinnakerCanaryConfig_multipleApplications("tf-unit-test"),
+			Check: resource.ComposeTestCheckFunc(
+				resource.TestCheckResourceAttrSet(resourceName, "id"),
+				resource.TestCheckResourceAttr(resourceName, "applications.#", "2"),
+				resource.TestCheckResourceAttr(resourceName, "classifier.0.group_weights.Group 1", "100"),
+			),
+		},
+	)
+}
+
+func TestResourceSpinnakerCanaryConfigCRUD(t *testing.T) {
+	gate := fakegate.New()
+	meta := testUnitMeta(gate)
+	r := resourceSpinnakerCanaryConfig()
+	d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"name":         "tf-unit-test",
+		"description":  testDesc,
+		"applications": []interface{}{"tf-unit-test"},
+		"config_json": `{
+  "configVersion": "1",
+  "judge": {"name": "NetflixACAJudge-v1.0", "judgeConfigurations": {}},
+  "templates": {},
+  "metrics": [{"name": "CPU", "groups": ["Group 1"], "scopeName": "default", "analysisConfigurations": {}, "query": {"type": "stackdriver"}}],
+  "classifier": {"groupWeights": {"Group 1": 100}}
+}`,
+	})
+
+	if diags := r.CreateContext(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("failed: %v", diags)
+	}
+	id := d.Id()
+	if id == "" || d.Get("name").(string) != "tf-unit-test" {
+		t.Fatalf("unexpected state after create: %v", d.State())
+	}
+
+	if err := d.Set("description", "Updated description"); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	if diags := r.UpdateContext(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("failed: %v", diags)
+	}
+	cfg := &canaryConfigRead{}
+	if err := gate.Client().GetCanaryConfig(id, cfg); err != nil || cfg.Description != "Updated description" {
+		t.Fatalf("expected the canary config updated in place, got %v: %v", cfg, err)
+	}
+
+	if diags := r.DeleteContext(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("failed: %v", diags)
+	}
+	if err := gate.Client().GetCanaryConfig(id, cfg); !errors.Is(err, api.ErrCodeNoSuchEntityException) {
+		t.Fatalf("expected the canary config to be deleted, got %v", err)
+	}
+}
+
+func TestResourceSpinnakerCanaryConfigCapability(t *testing.T) {
+	gate := fakegate.New()
+	meta := gateConfig{
+		client:       gate.Client(),
+		capabilities: api.NewCapabilities("1.28.0", map[api.Capability]bool{api.CapabilityCanary: false}),
+	}
+	r := resourceSpinnakerCanaryConfig()
+	d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"name":         "tf-unit-test",
+		"applications": []interface{}{"tf-unit-test"},
+	})
+
+	diags := r.CreateContext(context.Background(), d, meta)
+	if !diags.HasError() || !strings.Contains(diags[0].Summary, "canary analysis not enabled on this Gate (version 1.28.0)") {
+		t.Fatalf("expected the create to fail on the missing capability, got %v", diags)
+	}
+	if d.Id() != "" {
+		t.Fatalf("expected no canary config to be created, got %s", d.Id())
+	}
+
+	// A canary config left over is still refreshed and destroyed
+	id, err := gate.Client().CreateCanaryConfig(api.CanaryConfig{"name": "tf-unit-test", "applications": []interface{}{"tf-unit-test"}})
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	d.SetId(id)
+	if diags := r.ReadContext(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("failed: %v", diags)
+	}
+	if diags := r.DeleteContext(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("failed: %v", diags)
+	}
+}
+
+func TestResourceSpinnakerCanaryConfigReadMetrics(t *testing.T) {
+	gate := fakegate.New()
+	meta := testUnitMeta(gate)
+	r := resourceSpinnakerCanaryConfig()
+	d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"name":         "tf-unit-test",
+		"applications": []interface{}{"tf-unit-test"},
+		"metric": []interface{}{
+			map[string]interface{}{
+				"name":   "CPU",
+				"groups": []interface{}{"Group 1"},
+				"query": []interface{}{
+					map[string]interface{}{
+						"type":                 "stackdriver",
+						"service_type":         "stackdriver",
+						"resource_type":        "k8s_node",
+						"metric_type":          "kubernetes.io/node/cpu/core_usage_time",
+						"cross_series_reducer": "REDUCE_SUM",
+					},
+				},
+				"analysis_configurations": []interface{}{
+					map[string]interface{}{
+						"canary": []interface{}{
+							map[string]interface{}{"direction": "decrease"},
+						},
+					},
+				},
+			},
+		},
+		"classifier": []interface{}{
+			map[string]interface{}{"group_weights": map[string]interface{}{"Group 1": "100"}},
+		},
+	})
+
+	if diags := r.CreateContext(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("failed: %v", diags)
+	}
+
+	// Changed outside of Terraform
+	doc := map[string]interface{}{}
+	if err := gate.Client().GetCanaryConfig(d.Id(), &doc); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	doc["metrics"].([]interface{})[0].(map[string]interface{})["query"].(map[string]interface{})["metricType"] = "kubernetes.io/node/memory/used_bytes"
+	if err := gate.Client().UpdateCanaryConfig(d.Id(), doc); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	if diags := r.ReadContext(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("failed: %v", diags)
+	}
+	for k, expected := range map[string]string{
+		"metric.#":                                              "1",
+		"metric.0.name":                                         "CPU",
+		"metric.0.groups.0":                                     "Group 1",
+		"metric.0.query.0.metric_type":                          "kubernetes.io/node/memory/used_bytes",
+		"metric.0.query.0.resource_type":                        "k8s_node",
+		"metric.0.query.0.cross_series_reducer":                 "REDUCE_SUM",
+		"metric.0.analysis_configurations.0.canary.0.direction": "decrease",
+	} {
+		if got := d.State().Attributes[k]; got != expected {
+			t.Fatalf("expected %s to be %q, got %q", k, expected, got)
+		}
+	}
+}
+
+func TestResourceSpinnakerCanaryConfigImport(t *testing.T) {
+	gate := fakegate.New()
+	configJSON := `{
+  "configVersion": "1",
+  "judge": {"name": "NetflixACAJudge-v1.0", "judgeConfigurations": {}},
+  "templates": {},
+  "metrics": [{"name": "CPU", "groups": ["Group 1"], "scopeName": "default", "analysisConfigurations": {}, "query": {"type": "stackdriver"}}],
+  "classifier": {"groupWeights": {"Group 1": 100}}
+}`
+	cfg := api.CanaryConfig{}
+	if err := json.Unmarshal([]byte(configJSON), &cfg); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	cfg["name"] = "tf-unit-test"
+	cfg["applications"] = []string{"tf-unit-test"}
+	id, err := gate.Client().CreateCanaryConfig(cfg)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	r := resourceSpinnakerCanaryConfig()
+	d := r.Data(nil)
+	d.SetId(id)
+	ds, err := r.Importer.StateContext(context.Background(), d, testUnitMeta(gate))
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	got := ds[0].Get("config_json").(string)
+	if got == "" || !suppressEquivalentCanaryConfigDiffs("config_json", got, configJSON, ds[0]) {
+		t.Fatalf("expected the Kayenta document in config_json, got %q", got)
+	}
+	if n := ds[0].Get("metric.#").(int); n != 0 {
+		t.Fatalf("expected no metric blocks, got %d", n)
+	}
+}
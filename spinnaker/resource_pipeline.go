@@ -6,9 +6,25 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	api "github.com/himanhsugusain/terraform-provider-spinnaker/spinnaker/api"
+	"github.com/himanhsugusain/terraform-provider-spinnaker/spinnaker/normalize"
+)
+
+// pipelineManagedRules are the keys of a pipeline which are either managed
+// by Spinnaker or handled by other attributes
+var pipelineManagedRules = normalize.MustRules(
+	"application",
+	"lastModifiedBy",
+	"id",
+	"index",
+	"name",
+	"updateTs",
 )
 
 func resourcePipeline() *schema.Resource {
@@ -17,144 +33,288 @@ func resourcePipeline() *schema.Resource {
 		Schema: map[string]*schema.Schema{
 			"application": {
 				Type:         schema.TypeString,
-				ForceNew:     true,
-				Required:     true,
+				Description:  "Application of the pipeline, defaults to the default_application of the provider. The pipeline is moved in place, see move_in_projects",
+				Optional:     true,
+				Computed:     true,
 				ValidateFunc: validateSpinnakerApplicationName,
 			},
 			"name": {
-				Type:     schema.TypeString,
-				ForceNew: true,
-				Required: true,
+				Type:        schema.TypeString,
+				Description: "Name of the pipeline, it is renamed in place",
+				Required:    true,
 			},
 			"pipeline": {
 				Type:             schema.TypeString,
 				Required:         true,
 				DiffSuppressFunc: pipelineDiffSuppressFunc,
 			},
+			"ignore_paths": {
+				Type:        schema.TypeList,
+				Description: "Paths of the pipeline JSON whose changes are ignored, such as stages[*].refId or triggers[*].id",
+				Optional:    true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validateNormalizePath,
+				},
+			},
+			"move_in_projects": {
+				Type:        schema.TypeBool,
+				Description: "Whether moving the pipeline to another application also moves its pipeline configs in the Spinnaker projects and adds the application to them. Projects managed with spinnaker_project then differ from their configuration",
+				Optional:    true,
+				Default:     false,
+			},
 			"pipeline_id": {
 				Type:     schema.TypeString,
 				Computed: true,
 			},
+			"pipeline_changes": {
+				Type:        schema.TypeList,
+				Description: "Changes the last update of pipeline made to the pipeline JSON, such as stages[3].timeout: 300 -> 600, shown in its plan",
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"run_as_user": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "User, usually the service account of the owning team, the pipeline is written as instead of the provider's run_as_user",
+			},
 		},
-		Create: resourcePipelineCreate,
-		Read:   resourcePipelineRead,
-		Update: resourcePipelineUpdate,
-		Delete: resourcePipelineDelete,
-		Exists: resourcePipelineExists,
+		CustomizeDiff: customdiff.All(
+			customizeDiffDefault("application", "default_application"),
+			customizeDiffPipelineChanges,
+		),
+		CreateContext: resourcePipelineCreate,
+		ReadContext:   resourcePipelineRead,
+		UpdateContext: resourcePipelineUpdate,
+		DeleteContext: resourcePipelineDelete,
+		Exists:        resourcePipelineExists,
 		Importer: &schema.ResourceImporter{
 			StateContext: resourceSpinnakerPipelineImport,
 		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(10 * time.Minute),
+			Update: schema.DefaultTimeout(10 * time.Minute),
+			Delete: schema.DefaultTimeout(10 * time.Minute),
+		},
 	}
 }
 
+// pipelineClient returns the client the pipeline is managed with, the
+// pipeline data source shares the read of the resource and has no run_as_user
+func pipelineClient(ctx context.Context, data *schema.ResourceData, meta interface{}) api.Client {
+	clientConfig := meta.(gateConfig)
+	runAsUser, _ := data.Get("run_as_user").(string)
+	return clientConfig.client.WithRunAsUser(runAsUser).WithContext(ctx)
+}
+
 type pipelineRead struct {
 	Name        string `json:"name"`
 	Application string `json:"application"`
 	ID          string `json:"id"`
 }
 
-func resourcePipelineCreate(data *schema.ResourceData, meta interface{}) error {
-	clientConfig := meta.(gateConfig)
-	client := clientConfig.client
+func resourcePipelineCreate(ctx context.Context, data *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := pipelineClient(ctx, data, meta)
 
-	createPipelineTask, err := api.NewSavePipelineTask(data)
+	pipeline, err := pipelineDocument(data)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 
-	if err := api.CretePipeLineWithTask(client, createPipelineTask); err != nil {
-		fmt.Printf("Error: %v\n", err)
-		return err
+	createPipelineTask, err := api.NewSavePipelineTask(pipeline)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := client.CreatePipeline(createPipelineTask); err != nil {
+		return diagFromErr(err)
 	}
-	return resourcePipelineRead(data, meta)
+	return readPipeline(ctx, data, meta)
 }
 
-func resourcePipelineRead(data *schema.ResourceData, meta interface{}) error {
-	clientConfig := meta.(gateConfig)
-	client := clientConfig.client
-	applicationName := data.Get("application").(string)
+// resourcePipelineRead reads the pipeline and warns about the changes made to
+// it outside of Terraform, which a plan would otherwise show as two single
+// line documents
+func resourcePipelineRead(ctx context.Context, data *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	// Imported, or read by the data source, there is no pipeline in the
+	// state to compare with
+	stored, _ := data.Get("pipeline").(string)
+	tracked := data.Id() != "" && stored != ""
+
+	diags := readPipeline(ctx, data, meta)
+	if !tracked || diags.HasError() || data.Id() == "" {
+		return diags
+	}
+	return append(diags, pipelineDriftDiagnostics(data, stored, data.Get("pipeline").(string))...)
+}
+
+// readPipeline reads the pipeline into the state. Create and update read it
+// without warning, the pipeline of their state is the one just written.
+func readPipeline(ctx context.Context, data *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := pipelineClient(ctx, data, meta)
 	pipelineName := data.Get("name").(string)
 
 	var p pipelineRead
-	jsonMap, err := api.GetPipeline(client, applicationName, pipelineName, &p)
+	jsonMap, err := getPipeline(client, data, &p)
 	if err != nil {
-		return err
+		// The pipeline of the resource was deleted, the data source has no
+		// id and fails
+		if errors.Is(err, api.ErrCodeNoSuchEntityException) && data.Id() != "" {
+			data.SetId("")
+			return nil
+		}
+		return diag.FromErr(err)
+	}
+
+	if err := data.Set("application", p.Application); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := data.Set("name", p.Name); err != nil {
+		return diag.FromErr(err)
 	}
 
 	pipeline, err := editAndEncodePipeline(jsonMap)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 	err = data.Set("pipeline", pipeline)
 	if err != nil {
-		return fmt.Errorf("Could not set pipeline for pipeline %s: %s", pipelineName, err)
+		return diag.Errorf("Could not set pipeline for pipeline %s: %s", pipelineName, err)
 	}
 
 	err = data.Set("pipeline_id", p.ID)
 	if err != nil {
-		return fmt.Errorf("Could not set pipeline_id for pipeline %s: %s", pipelineName, err)
+		return diag.Errorf("Could not set pipeline_id for pipeline %s: %s", pipelineName, err)
 	}
 	data.SetId(p.ID)
 
 	return nil
 }
 
-func resourcePipelineUpdate(data *schema.ResourceData, meta interface{}) error {
-	clientConfig := meta.(gateConfig)
-	client := clientConfig.client
+// getPipeline returns the pipeline of the resource by its pipeline_id, so that
+// it is found when it was renamed outside of Terraform. It is read by its name
+// first, which is served by the cache of the pipelines of its application.
+func getPipeline(client api.Client, data *schema.ResourceData, dest *pipelineRead) (map[string]interface{}, error) {
+	applicationName := data.Get("application").(string)
+	pipelineName := data.Get("name").(string)
+	pipelineID := data.Id()
+
+	// Imported by id, the application and the name are not known yet
+	if pipelineID != "" && (applicationName == "" || pipelineName == "") {
+		return client.GetPipelineByID(pipelineID, dest)
+	}
+
+	jsonMap, err := client.GetPipeline(applicationName, pipelineName, dest)
+	if pipelineID == "" || (err == nil && dest.ID == pipelineID) {
+		return jsonMap, err
+	}
+	if err != nil && !errors.Is(err, api.ErrCodeNoSuchEntityException) {
+		return nil, err
+	}
+
+	// Renamed, or another pipeline has its name now
+	*dest = pipelineRead{}
+	return client.GetPipelineByID(pipelineID, dest)
+}
+
+func resourcePipelineUpdate(ctx context.Context, data *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := pipelineClient(ctx, data, meta)
 	applicationName := data.Get("application").(string)
 	pipelineName := data.Get("name").(string)
-	pipeline := data.Get("pipeline").(string)
 
 	pipelineID, ok := data.GetOk("pipeline_id")
 	if !ok {
-		return fmt.Errorf("No pipeline_id found to pipeline in %s with name %s", applicationName, pipelineName)
+		return diag.Errorf("No pipeline_id found to pipeline in %s with name %s", applicationName, pipelineName)
 	}
 
-	var pipe map[string]interface{}
-	err := json.Unmarshal([]byte(pipeline), &pipe)
+	pipe, err := pipelineDocument(data)
 	if err != nil {
-		return fmt.Errorf("could not unmarshal pipeline")
+		return diag.FromErr(err)
 	}
-
-	pipe["application"] = applicationName
-	pipe["name"] = pipelineName
 	pipe["id"] = pipelineID.(string)
 
-	if err := api.UpdatePipeline(client, pipelineID.(string), pipe); err != nil {
+	if err := client.UpdatePipeline(pipelineID.(string), pipe); err != nil {
+		return diag.FromErr(err)
+	}
+	if data.HasChange("application") && data.Get("move_in_projects").(bool) {
+		if err := movePipelineInProjects(client, pipelineID.(string), applicationName); err != nil {
+			return diag.Errorf("pipeline moved to %s, but not in its projects: %s", applicationName, err)
+		}
+	}
+	return readPipeline(ctx, data, meta)
+}
+
+// pipelineDocument returns the pipeline JSON with the application and name of
+// the resource, which Front50 saves it under. The JSON may omit them, such as
+// when the application is the default_application of the provider.
+func pipelineDocument(data *schema.ResourceData) (map[string]interface{}, error) {
+	var pipeline map[string]interface{}
+	if err := json.Unmarshal([]byte(data.Get("pipeline").(string)), &pipeline); err != nil {
+		return nil, fmt.Errorf("could not unmarshal pipeline: %s", err)
+	}
+
+	pipeline["application"] = data.Get("application").(string)
+	pipeline["name"] = data.Get("name").(string)
+	return pipeline, nil
+}
+
+// movePipelineInProjects points the pipeline configs of the projects at the
+// pipeline moved to application
+func movePipelineInProjects(client api.Client, pipelineID, application string) error {
+	projects, err := client.GetProjects()
+	if err != nil {
 		return err
 	}
-	return resourcePipelineRead(data, meta)
+
+	for _, project := range projects {
+		task, ok := api.NewMovePipelineProjectTask(project, pipelineID, application)
+		if !ok {
+			continue
+		}
+		if err := client.CreateProject(task); err != nil {
+			return fmt.Errorf("could not update project %v: %w", project["name"], err)
+		}
+	}
+	return nil
 }
 
-func resourcePipelineDelete(data *schema.ResourceData, meta interface{}) error {
-	clientConfig := meta.(gateConfig)
-	client := clientConfig.client
+func resourcePipelineDelete(ctx context.Context, data *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := pipelineClient(ctx, data, meta)
 	applicationName := data.Get("application").(string)
 	pipelineName := data.Get("name").(string)
 
-	if err := api.DeletePipeline(client, applicationName, pipelineName); err != nil {
-		return err
+	if err := client.DeletePipeline(applicationName, pipelineName); err != nil {
+		return diag.FromErr(err)
 	}
 
 	return nil
 }
 
+// resourceSpinnakerPipelineImport imports a pipeline by its id, or by
+// <application>.<pipeline>, application names have no dots
 func resourceSpinnakerPipelineImport(ctx context.Context, data *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
-	application, name, err := resourceSpinnakerPipelineParseId(data.Id())
-	if err != nil {
-		return nil, err
-	}
-	if err := data.Set("application", application); err != nil {
-		return nil, err
-	}
-	if err := data.Set("name", name); err != nil {
-		return nil, err
+	importID := data.Id()
+	if strings.Contains(importID, ".") {
+		application, name, err := resourceSpinnakerPipelineParseId(data.Id())
+		if err != nil {
+			return nil, err
+		}
+		if err := data.Set("application", application); err != nil {
+			return nil, err
+		}
+		if err := data.Set("name", name); err != nil {
+			return nil, err
+		}
+		// Read by the name, the id is set from the pipeline
+		data.SetId("")
 	}
 
-	if err := resourcePipelineRead(data, meta); err != nil {
+	if diags := readPipeline(ctx, data, meta); diags.HasError() {
 		return nil, fmt.Errorf("failed to read spinnaker pipeline")
 	}
+	if data.Id() == "" {
+		return nil, fmt.Errorf("spinnaker pipeline %s not found", importID)
+	}
 	return []*schema.ResourceData{data}, nil
 }
 
@@ -162,20 +322,17 @@ func resourceSpinnakerPipelineParseId(id string) (string, string, error) {
 	parts := strings.SplitN(id, ".", 2)
 
 	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
-		return "", "", fmt.Errorf("unexpected format of ID (%s), expected <application>.<pipeline>", id)
+		return "", "", fmt.Errorf("unexpected format of ID (%s), expected <pipeline id> or <application>.<pipeline>", id)
 	}
 
 	return parts[0], parts[1], nil
 }
 
 func resourcePipelineExists(data *schema.ResourceData, meta interface{}) (bool, error) {
-	clientConfig := meta.(gateConfig)
-	client := clientConfig.client
-	applicationName := data.Get("application").(string)
-	pipelineName := data.Get("name").(string)
+	client := pipelineClient(context.Background(), data, meta)
 
 	var p pipelineRead
-	if _, err := api.GetPipeline(client, applicationName, pipelineName, &p); err != nil {
+	if _, err := getPipeline(client, data, &p); err != nil {
 		// the error states that it does not exists (when the check happened.)
 		if errors.Is(err, api.ErrCodeNoSuchEntityException) {
 			return false, nil
@@ -194,12 +351,21 @@ func pipelineDiffSuppressFunc(k, old, new string, d *schema.ResourceData) bool {
 	// Spinnaker does non-trivial modifications to the JSON for a pipeline,
 	// so we round-trip decode, edit, and encode the user's pipeline
 	// spec, and compare against the decoded, edited, and encoded new pipeline.
-	editedOld, err := decodeEditAndEncodePipeline(old)
+	var ignorePaths interface{}
+	if d != nil {
+		ignorePaths = d.Get("ignore_paths")
+	}
+	rules, err := pipelineRules(ignorePaths)
+	if err != nil {
+		return false
+	}
+
+	editedOld, err := rules.Normalize(old)
 	if err != nil {
 		return false
 	}
 
-	editedNew, err := decodeEditAndEncodePipeline(new)
+	editedNew, err := rules.Normalize(new)
 	if err != nil {
 		return false
 	}
@@ -207,37 +373,103 @@ func pipelineDiffSuppressFunc(k, old, new string, d *schema.ResourceData) bool {
 	return editedOld == editedNew
 }
 
-func decodeEditAndEncodePipeline(pipeline string) (encodedPipeline string, err error) {
-	// Decode the pipeline into a map we can edit
-	pipelineBytes := []byte(pipeline)
-	var pipelineMapGeneric interface{}
-	err = json.Unmarshal(pipelineBytes, &pipelineMapGeneric)
+// customizeDiffPipelineChanges plans the changes to the pipeline JSON as
+// pipeline_changes, so that they are reviewed path by path rather than as two
+// single line documents. The SDK has no warnings in plans. The attribute keeps
+// the changes of the last update of pipeline, a plan without one leaves it.
+func customizeDiffPipelineChanges(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if d.Id() == "" || !d.HasChange("pipeline") || !d.NewValueKnown("pipeline") {
+		return nil
+	}
+
+	old, new := d.GetChange("pipeline")
+	changes := pipelineChanges(d.Get("ignore_paths"), old.(string), new.(string))
+	if len(changes) == 0 {
+		return nil
+	}
+
+	lines := make([]interface{}, len(changes))
+	for i, c := range changes {
+		lines[i] = c
+	}
+	return d.SetNew("pipeline_changes", lines)
+}
+
+// pipelineDriftDiagnostics warns about the changes from the pipeline of the
+// state to the one read from Spinnaker
+func pipelineDriftDiagnostics(data *schema.ResourceData, stored, read string) diag.Diagnostics {
+	changes := pipelineChanges(data.Get("ignore_paths"), stored, read)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	detail := []string{fmt.Sprintf("Pipeline %s of application %s differs from the state:", data.Get("name"), data.Get("application"))}
+	for _, c := range changes {
+		detail = append(detail, "  "+c)
+	}
+	return diag.Diagnostics{{
+		Severity:      diag.Warning,
+		Summary:       "Pipeline changed outside of Terraform",
+		Detail:        strings.Join(detail, "\n"),
+		AttributePath: cty.GetAttrPath("pipeline"),
+	}}
+}
+
+// pipelineChanges returns the changes from the pipeline JSON old to new, one
+// per changed path such as stages[3].timeout: 300 -> 600, leaving out the
+// keys managed by Spinnaker and the ignore_paths. Documents which are not
+// JSON have none.
+func pipelineChanges(ignorePaths interface{}, old, new string) []string {
+	rules, err := pipelineRules(ignorePaths)
+	if err != nil {
+		return nil
+	}
+	editedOld, err := rules.Normalize(old)
+	if err != nil {
+		return nil
+	}
+	editedNew, err := rules.Normalize(new)
 	if err != nil {
-		return
+		return nil
 	}
 
-	pipelineMap := pipelineMapGeneric.(map[string]interface{})
+	changes, err := normalize.Diff(editedOld, editedNew)
+	if err != nil {
+		return nil
+	}
 
-	return editAndEncodePipeline(pipelineMap)
+	lines := make([]string, len(changes))
+	for i, c := range changes {
+		lines[i] = c.String()
+	}
+	return lines
+}
+
+// pipelineRules returns the keys left out of the comparison of pipelines, the
+// ones managed by Spinnaker and the ignore_paths of the resource
+func pipelineRules(ignorePaths interface{}) (normalize.Rules, error) {
+	raw, _ := ignorePaths.([]interface{})
+	paths := make([]string, 0, len(raw))
+	for _, p := range raw {
+		if p, ok := p.(string); ok {
+			paths = append(paths, p)
+		}
+	}
+	return pipelineManagedRules.With(paths...)
+}
+
+func validateNormalizePath(v interface{}, k string) (ws []string, errors []error) {
+	if _, err := normalize.ParsePath(v.(string)); err != nil {
+		errors = append(errors, fmt.Errorf("invalid %q: %s", k, err))
+	}
+
+	return
 }
 
 func editAndEncodePipeline(pipelineMap map[string]interface{}) (encodedPipeline string, err error) {
 	// Remove the keys we know are problematic because they are managed
 	// by spinnaker or are handled by other schema attributes.
-	delete(pipelineMap, "application")
-	delete(pipelineMap, "lastModifiedBy")
-	delete(pipelineMap, "id")
-	delete(pipelineMap, "index")
-	delete(pipelineMap, "name")
-	delete(pipelineMap, "updateTs")
-
 	// Encode the pipeline into a single string
 	// This will sort all keys, etc.
-	editedPipelineBytes, err := json.Marshal(pipelineMap)
-	if err != nil {
-		return
-	}
-
-	encodedPipeline = string(editedPipelineBytes)
-	return
+	return pipelineManagedRules.Encode(pipelineMap)
 }
@@ -2,17 +2,23 @@ package spinnaker
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"reflect"
 
 	"github.com/ghodss/yaml"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	api "github.com/himanhsugusain/terraform-provider-spinnaker/spinnaker/api"
+	"github.com/himanhsugusain/terraform-provider-spinnaker/spinnaker/normalize"
 )
 
+// pipelineTemplateManagedRules are the keys of a pipeline template set by
+// Front50
+var pipelineTemplateManagedRules = normalize.MustRules("updateTs", "lastModifiedBy")
+
 func resourcePipelineTemplate() *schema.Resource {
 	return &schema.Resource{
 		DeprecationMessage: "Pipeline template deprecated because is not HCL native. We plan to support in the next major release",
@@ -27,11 +33,11 @@ func resourcePipelineTemplate() *schema.Resource {
 				Computed: true,
 			},
 		},
-		Create: resourcePipelineTemplateCreate,
-		Read:   resourcePipelineTemplateRead,
-		Update: resourcePipelineTemplateUpdate,
-		Delete: resourcePipelineTemplateDelete,
-		Exists: resourcePipelineTemplateExists,
+		CreateContext: resourcePipelineTemplateCreate,
+		ReadContext:   resourcePipelineTemplateRead,
+		UpdateContext: resourcePipelineTemplateUpdate,
+		DeleteContext: resourcePipelineTemplateDelete,
+		CustomizeDiff: customizeDiffRequire(api.CapabilityPipelineTemplates),
 	}
 }
 
@@ -39,65 +45,64 @@ type templateRead struct {
 	ID string `json:"id"`
 }
 
-func resourcePipelineTemplateCreate(data *schema.ResourceData, meta interface{}) error {
+func resourcePipelineTemplateCreate(ctx context.Context, data *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	clientConfig := meta.(gateConfig)
-	client := clientConfig.client
+	if err := clientConfig.require(ctx, api.CapabilityPipelineTemplates); err != nil {
+		return diag.FromErr(err)
+	}
+	client := clientConfig.client.WithContext(ctx)
 	var templateName string
 	template := data.Get("template").(string)
 
 	d, err := yaml.YAMLToJSON([]byte(template))
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 
 	var jsonContent map[string]interface{}
 	if err = json.NewDecoder(bytes.NewReader(d)).Decode(&jsonContent); err != nil {
-		return fmt.Errorf("Error decoding json: %s", err.Error())
+		return diag.Errorf("Error decoding json: %s", err.Error())
 	}
 
 	if _, ok := jsonContent["schema"]; !ok {
-		return fmt.Errorf("Pipeline save command currently only supports pipeline template configurations")
+		return diag.Errorf("Pipeline save command currently only supports pipeline template configurations")
 	}
 
 	templateName = jsonContent["id"].(string)
 
-	log.Println("[DEBUG] Making request to spinnaker")
-	if err := api.CreatePipelineTemplate(client, jsonContent); err != nil {
-		log.Printf("[DEBUG] Error response from spinnaker: %s", err.Error())
-		return err
+	if err := client.CreatePipelineTemplate(jsonContent); err != nil {
+		return diag.FromErr(err)
 	}
 
-	log.Printf("[DEBUG] Created template successfully")
 	data.SetId(templateName)
-	return resourcePipelineTemplateRead(data, meta)
+	return resourcePipelineTemplateRead(ctx, data, meta)
 }
 
-func resourcePipelineTemplateRead(data *schema.ResourceData, meta interface{}) error {
+func resourcePipelineTemplateRead(ctx context.Context, data *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	clientConfig := meta.(gateConfig)
-	client := clientConfig.client
+	client := clientConfig.client.WithContext(ctx)
 	templateName := data.Id()
 
 	t := make(map[string]interface{})
-	if err := api.GetPipelineTemplate(client, templateName, &t); err != nil {
+	if err := client.GetPipelineTemplate(templateName, &t); err != nil {
 		if errors.Is(err, api.ErrCodeNoSuchEntityException) {
 			data.SetId("")
 			return nil
 		}
-		return err
+		return diag.FromErr(err)
 	}
 
 	// Remove timestamp from response
-	delete(t, "updateTs")
-	delete(t, "lastModifiedBy")
+	pipelineTemplateManagedRules.Apply(t)
 
 	jsonContent, err := json.Marshal(t)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 
 	raw, err := yaml.JSONToYAML(jsonContent)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 	data.Set("name", t["id"].(string))
 	data.Set("template", string(raw))
@@ -107,69 +112,52 @@ func resourcePipelineTemplateRead(data *schema.ResourceData, meta interface{}) e
 	return nil
 }
 
-func resourcePipelineTemplateUpdate(data *schema.ResourceData, meta interface{}) error {
+func resourcePipelineTemplateUpdate(ctx context.Context, data *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	clientConfig := meta.(gateConfig)
-	client := clientConfig.client
+	if err := clientConfig.require(ctx, api.CapabilityPipelineTemplates); err != nil {
+		return diag.FromErr(err)
+	}
+	client := clientConfig.client.WithContext(ctx)
 	var templateName string
 	template := data.Get("template").(string)
 
 	d, err := yaml.YAMLToJSON([]byte(template))
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 
 	var jsonContent map[string]interface{}
 	if err = json.NewDecoder(bytes.NewReader(d)).Decode(&jsonContent); err != nil {
-		return fmt.Errorf("Error decoding json: %s", err.Error())
+		return diag.Errorf("Error decoding json: %s", err.Error())
 	}
 
 	if _, ok := jsonContent["schema"]; !ok {
-		return fmt.Errorf("Pipeline save command currently only supports pipeline template configurations")
+		return diag.Errorf("Pipeline save command currently only supports pipeline template configurations")
 	}
 
 	templateName = jsonContent["id"].(string)
 
-	if err := api.UpdatePipelineTemplate(client, templateName, jsonContent); err != nil {
-		return err
+	if err := client.UpdatePipelineTemplate(templateName, jsonContent); err != nil {
+		return diag.FromErr(err)
 	}
 
 	data.SetId(templateName)
-	return resourcePipelineTemplateRead(data, meta)
+	return resourcePipelineTemplateRead(ctx, data, meta)
 }
 
-func resourcePipelineTemplateDelete(data *schema.ResourceData, meta interface{}) error {
+func resourcePipelineTemplateDelete(ctx context.Context, data *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	clientConfig := meta.(gateConfig)
-	client := clientConfig.client
+	client := clientConfig.client.WithContext(ctx)
 	templateName := data.Id()
 
-	if err := api.DeletePipelineTemplate(client, templateName); err != nil {
-		return err
+	if err := client.DeletePipelineTemplate(templateName); err != nil {
+		return diag.FromErr(err)
 	}
 
 	data.SetId("")
 	return nil
 }
 
-func resourcePipelineTemplateExists(data *schema.ResourceData, meta interface{}) (bool, error) {
-	clientConfig := meta.(gateConfig)
-	client := clientConfig.client
-	templateName := data.Id()
-
-	t := &templateRead{}
-	if err := api.GetPipelineTemplate(client, templateName, t); err != nil {
-		if errors.Is(err, api.ErrCodeNoSuchEntityException) {
-			return false, nil
-		}
-		return false, err
-	}
-
-	if t.ID == templateName {
-		return true, nil
-	}
-
-	return false, nil
-}
-
 func suppressEquivalentPipelineTemplateDiffs(k, old, new string, d *schema.ResourceData) bool {
 	equivalent, err := areEqualJSON(old, new)
 	if err != nil {
@@ -184,12 +172,10 @@ func areEqualJSON(s1, s2 string) (bool, error) {
 	var o2 interface{}
 
 	var err error
-	log.Printf("[DEBUG] s1: %s", s1)
 	err = yaml.Unmarshal([]byte(s1), &o1)
 	if err != nil {
 		return false, fmt.Errorf("Error mashalling string 1 :: %s", err.Error())
 	}
-	log.Printf("[DEBUG] s2: %s", s2)
 	err = yaml.Unmarshal([]byte(s2), &o2)
 	if err != nil {
 		return false, fmt.Errorf("Error mashalling string 2 :: %s", err.Error())
@@ -0,0 +1,65 @@
+package spinnaker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/himanhsugusain/terraform-provider-spinnaker/spinnaker/fakegate"
+)
+
+const testUnitPipelineTemplate = `schema: v2
+id: tf-unit-test
+metadata:
+  name: %s
+  description: Unit test template
+  owner: acceptance@test.com
+  scopes:
+  - global
+variables: []
+pipeline:
+  stages: []
+`
+
+func testUnitCheckSpinnakerPipelineTemplateDeleted(gate *fakegate.Gate, templateID string) resource.TestCheckFunc {
+	return testUnitCheckDeleted("pipeline template "+templateID, func() error {
+		return gate.Client().GetPipelineTemplate(templateID, &templateRead{})
+	})
+}
+
+func TestResourceSpinnakerPipelineTemplateCRUD(t *testing.T) {
+	gate := fakegate.New()
+	meta := testUnitMeta(gate)
+	r := resourcePipelineTemplate()
+	d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"template": fmt.Sprintf(testUnitPipelineTemplate, "Deploy"),
+	})
+
+	if diags := r.CreateContext(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("failed: %v", diags)
+	}
+	if d.Id() != "tf-unit-test" || d.Get("url").(string) != "spinnaker://tf-unit-test" {
+		t.Fatalf("unexpected state after create: %v", d.State())
+	}
+
+	if err := d.Set("template", fmt.Sprintf(testUnitPipelineTemplate, "Deploy to production")); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	if diags := r.UpdateContext(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("failed: %v", diags)
+	}
+	if !strings.Contains(d.Get("template").(string), "Deploy to production") {
+		t.Fatalf("expected the updated template, got %s", d.Get("template"))
+	}
+
+	if diags := r.DeleteContext(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("failed: %v", diags)
+	}
+	if err := testUnitCheckSpinnakerPipelineTemplateDeleted(gate, "tf-unit-test")(nil); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+}
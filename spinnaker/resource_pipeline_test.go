@@ -1,20 +1,29 @@
 package spinnaker
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
+	ctyjson "github.com/hashicorp/go-cty/cty/json"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 
 	api "github.com/himanhsugusain/terraform-provider-spinnaker/spinnaker/api"
+	"github.com/himanhsugusain/terraform-provider-spinnaker/spinnaker/fakegate"
 )
 
 func TestAccResourceSourceSpinnakerPipeline_basic(t *testing.T) {
-	resourceName := acctest.RandomWithPrefix("tf-acc-test")
-	application := acctest.RandomWithPrefix("tf-acc-test")
+	cassette := newTestAccCassette(t)
+	resourceName := cassette.RandomName("tf-acc-test-pipeline")
+	application := cassette.RandomName("tf-acc-test")
 	pipeline := `{
   		"keepWaitingPipelines": false,
   		"limitConcurrent": true,
@@ -24,21 +33,21 @@ func TestAccResourceSourceSpinnakerPipeline_basic(t *testing.T) {
 		}`
 
 	resource.ParallelTest(t, resource.TestCase{
-		PreCheck:     func() { testAccPreCheck(t) },
-		Providers:    testAccProviders,
-		CheckDestroy: testAccCheckSpinnakerPipelineDestroy("spinnaker_pipeline.test", application),
+		PreCheck:     cassette.PreCheck,
+		Providers:    cassette.Providers(),
+		CheckDestroy: testAccCheckSpinnakerPipelineDestroy(cassette.provider, "spinnaker_pipeline.test", application),
 		Steps: []resource.TestStep{
 			{
 				Config: testAccSpinnakerPipeline_basic(resourceName, application, pipeline),
 				Check: resource.ComposeTestCheckFunc(
-					testAccCheckSpinnakerPipelineExists("spinnaker_pipeline.test", application),
+					testAccCheckSpinnakerPipelineExists(cassette.provider, "spinnaker_pipeline.test", application),
 					resource.TestCheckResourceAttr("spinnaker_pipeline.test", "name", resourceName),
 					resource.TestCheckResourceAttr("spinnaker_pipeline.test", "application", application),
 				),
 			},
 			{
 				ResourceName:      "spinnaker_pipeline.test",
-				ImportStateId:     fmt.Sprintf("%s%s", application, resourceName),
+				ImportStateId:     fmt.Sprintf("%s.%s", application, resourceName),
 				ImportState:       true,
 				ImportStateVerify: true,
 			},
@@ -46,12 +55,12 @@ func TestAccResourceSourceSpinnakerPipeline_basic(t *testing.T) {
 	})
 }
 
-func testAccCheckSpinnakerPipelineDestroy(resourceName string, applicationName string) resource.TestCheckFunc {
+func testAccCheckSpinnakerPipelineDestroy(p *schema.Provider, resourceName string, applicationName string) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
 		rs, ok := s.RootModule().Resources[resourceName]
 		pipelineName := rs.Primary.Attributes["name"]
 
-		client := testAccProvider.Meta().(gateConfig).client
+		client := p.Meta().(gateConfig).client
 		pipeline := &pipelineRead{}
 
 		if !ok {
@@ -62,7 +71,7 @@ func testAccCheckSpinnakerPipelineDestroy(resourceName string, applicationName s
 			return fmt.Errorf("No Pipeline ID is set")
 		}
 
-		if _, err := api.GetPipeline(client, applicationName, pipelineName, pipeline); err != nil {
+		if _, err := client.GetPipeline(applicationName, pipelineName, pipeline); err != nil {
 			if errors.Is(err, api.ErrCodeNoSuchEntityException) {
 				return nil
 			}
@@ -73,12 +82,12 @@ func testAccCheckSpinnakerPipelineDestroy(resourceName string, applicationName s
 	}
 }
 
-func testAccCheckSpinnakerPipelineExists(resourceName string, applicationName string) resource.TestCheckFunc {
+func testAccCheckSpinnakerPipelineExists(p *schema.Provider, resourceName string, applicationName string) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
 		rs, ok := s.RootModule().Resources[resourceName]
 		pipelineName := rs.Primary.Attributes["name"]
 
-		client := testAccProvider.Meta().(gateConfig).client
+		client := p.Meta().(gateConfig).client
 		pipeline := &pipelineRead{}
 
 		if !ok {
@@ -89,7 +98,7 @@ func testAccCheckSpinnakerPipelineExists(resourceName string, applicationName st
 			return fmt.Errorf("No Pipeline ID is set")
 		}
 
-		if _, err := api.GetPipeline(client, applicationName, pipelineName, pipeline); err != nil {
+		if _, err := client.GetPipeline(applicationName, pipelineName, pipeline); err != nil {
 			return err
 		}
 
@@ -111,3 +120,606 @@ resource "spinnaker_pipeline" "test" {
 }
 `, application, rName, application, pipeline)
 }
+
+func TestResourceSpinnakerPipeline_unit(t *testing.T) {
+	gate := fakegate.New()
+	testUnitTest(t, gate, testUnitCheckSpinnakerPipelineDeleted(gate, "tf-unit-test", "deploy"),
+		resource.TestStep{
+			Config: testAccSpinnakerPipeline_basic("deploy", "tf-unit-test", `{"limitConcurrent": true, "stages": []}`),
+			Check: resource.ComposeTestCheckFunc(
+				resource.TestCheckResourceAttrSet("spinnaker_pipeline.test", "pipeline_id"),
+				resource.TestCheckResourceAttr("spinnaker_pipeline.test", "name", "deploy"),
+			),
+		},
+		resource.TestStep{
+			Config: testAccSpinnakerPipeline_basic("deploy", "tf-unit-test", `{"limitConcurrent": false, "stages": []}`),
+			Check:  resource.TestCheckResourceAttr("spinnaker_pipeline.test", "pipeline", `{"limitConcurrent":false,"stages":[]}`),
+		},
+	)
+}
+
+func testUnitCheckSpinnakerPipelineDeleted(gate *fakegate.Gate, applicationName, pipelineName string) resource.TestCheckFunc {
+	return testUnitCheckDeleted("pipeline "+applicationName+"."+pipelineName, func() error {
+		_, err := gate.Client().GetPipeline(applicationName, pipelineName, &pipelineRead{})
+		return err
+	})
+}
+
+func TestResourceSpinnakerPipelineCRUD(t *testing.T) {
+	gate := fakegate.New()
+	meta := testUnitMeta(gate)
+	r := resourcePipeline()
+	d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"application": "tf-unit-test",
+		"name":        "deploy",
+		"pipeline":    `{"limitConcurrent": true, "stages": []}`,
+		"run_as_user": "team-svc",
+	})
+
+	if diags := r.CreateContext(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("failed: %v", diags)
+	}
+
+	pipelines := gate.Pipelines("tf-unit-test")
+	if len(pipelines) != 1 || pipelines[0]["name"] != "deploy" || pipelines[0]["lastModifiedBy"] != "team-svc" {
+		t.Fatalf("expected the pipeline saved with its name as team-svc, got %v", pipelines)
+	}
+	id := d.Id()
+	if id == "" || d.Get("pipeline_id").(string) != id {
+		t.Fatalf("expected the pipeline id in the state, got %v", d.State())
+	}
+	if got := d.Get("pipeline").(string); got != `{"limitConcurrent":true,"stages":[]}` {
+		t.Fatalf("expected the pipeline without the fields managed by Spinnaker, got %s", got)
+	}
+
+	if err := d.Set("pipeline", `{"limitConcurrent": false, "stages": []}`); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	if diags := r.UpdateContext(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("failed: %v", diags)
+	}
+	if d.Id() != id || d.Get("pipeline").(string) != `{"limitConcurrent":false,"stages":[]}` {
+		t.Fatalf("expected the pipeline updated in place, got %v", d.State())
+	}
+
+	if diags := r.DeleteContext(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("failed: %v", diags)
+	}
+	if err := testUnitCheckSpinnakerPipelineDeleted(gate, "tf-unit-test", "deploy")(nil); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+}
+
+func TestResourceSpinnakerPipelineRename(t *testing.T) {
+	gate := fakegate.New()
+	r := resourcePipeline()
+	d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"application": "tf-unit-test",
+		"name":        "deploy",
+		"pipeline":    `{"stages": []}`,
+	})
+	if diags := r.CreateContext(context.Background(), d, testUnitMeta(gate)); diags.HasError() {
+		t.Fatalf("failed: %v", diags)
+	}
+	id := d.Id()
+
+	// Renamed in place by the update, the id and its history are kept
+	if err := d.Set("name", "deploy-prod"); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	if diags := r.UpdateContext(context.Background(), d, testUnitMeta(gate)); diags.HasError() {
+		t.Fatalf("failed: %v", diags)
+	}
+	pipelines := gate.Pipelines("tf-unit-test")
+	if len(pipelines) != 1 || pipelines[0]["id"] != id || pipelines[0]["name"] != "deploy-prod" {
+		t.Fatalf("expected the pipeline renamed in place, got %v", pipelines)
+	}
+
+	// Renamed outside of Terraform, the refresh finds it by its id
+	pipeline := pipelines[0]
+	pipeline["name"] = "deploy-renamed"
+	if err := gate.Client().UpdatePipeline(id, pipeline); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	if diags := r.ReadContext(context.Background(), d, testUnitMeta(gate)); diags.HasError() {
+		t.Fatalf("failed: %v", diags)
+	}
+	if d.Id() != id || d.Get("name").(string) != "deploy-renamed" {
+		t.Fatalf("expected the pipeline found by its id, got %v", d.State())
+	}
+
+	// Deleted outside of Terraform, the refresh removes it from the state
+	if err := gate.Client().DeletePipeline("tf-unit-test", "deploy-renamed"); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	if diags := r.ReadContext(context.Background(), d, testUnitMeta(gate)); diags.HasError() {
+		t.Fatalf("failed: %v", diags)
+	}
+	if d.Id() != "" {
+		t.Fatalf("expected the pipeline to be removed from the state, got %v", d.State())
+	}
+}
+
+func TestResourceSpinnakerPipelineImport(t *testing.T) {
+	gate := fakegate.New()
+	meta := testUnitMeta(gate)
+	r := resourcePipeline()
+	d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"application": "tf-unit-test",
+		"name":        "deploy",
+		"pipeline":    `{"stages": []}`,
+	})
+	if diags := r.CreateContext(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("failed: %v", diags)
+	}
+	id := d.Id()
+
+	tcs := map[string]struct {
+		importID string
+		err      string
+	}{
+		"id":               {importID: id},
+		"application.name": {importID: "tf-unit-test.deploy"},
+		"missing id":       {importID: "00000000-missing", err: "spinnaker pipeline 00000000-missing not found"},
+		"missing name":     {importID: "tf-unit-test.missing", err: "failed to read spinnaker pipeline"},
+	}
+
+	for name, tc := range tcs {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			d := r.Data(&terraform.InstanceState{ID: tc.importID})
+			states, err := r.Importer.StateContext(context.Background(), d, meta)
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Fatalf("expected %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed: %v", err)
+			}
+
+			imported := states[0]
+			if imported.Id() != id || imported.Get("application").(string) != "tf-unit-test" || imported.Get("name").(string) != "deploy" {
+				t.Fatalf("expected the pipeline imported by its id, got %v", imported.State())
+			}
+		})
+	}
+}
+
+// testUnitPipelineReads is a client recording the applications whose
+// pipelines are read, the clients derived from it share the record
+type testUnitPipelineReads struct {
+	api.Client
+	mu           *sync.Mutex
+	applications *[]string
+}
+
+func newTestUnitPipelineReads(client api.Client) *testUnitPipelineReads {
+	return &testUnitPipelineReads{Client: client, mu: &sync.Mutex{}, applications: &[]string{}}
+}
+
+func (c *testUnitPipelineReads) WithRunAsUser(user string) api.Client {
+	return &testUnitPipelineReads{Client: c.Client.WithRunAsUser(user), mu: c.mu, applications: c.applications}
+}
+
+func (c *testUnitPipelineReads) WithContext(ctx context.Context) api.Client {
+	return &testUnitPipelineReads{Client: c.Client.WithContext(ctx), mu: c.mu, applications: c.applications}
+}
+
+func (c *testUnitPipelineReads) GetPipeline(applicationName, pipelineName string, dest interface{}) (map[string]interface{}, error) {
+	c.read(applicationName)
+	return c.Client.GetPipeline(applicationName, pipelineName, dest)
+}
+
+func (c *testUnitPipelineReads) GetPipelines(applicationName string) ([]map[string]interface{}, error) {
+	c.read(applicationName)
+	return c.Client.GetPipelines(applicationName)
+}
+
+func (c *testUnitPipelineReads) read(applicationName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	*c.applications = append(*c.applications, applicationName)
+}
+
+func TestResourceSpinnakerPipelineImportByIDReadsByID(t *testing.T) {
+	gate := fakegate.New()
+	r := resourcePipeline()
+	d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"application": "tf-unit-test",
+		"name":        "deploy",
+		"pipeline":    `{"stages": []}`,
+	})
+	if diags := r.CreateContext(context.Background(), d, testUnitMeta(gate)); diags.HasError() {
+		t.Fatalf("failed: %v", diags)
+	}
+
+	reads := newTestUnitPipelineReads(gate.Client())
+	meta := gateConfig{client: reads}
+	imported := r.Data(&terraform.InstanceState{ID: d.Id()})
+	if _, err := r.Importer.StateContext(context.Background(), imported, meta); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	for _, application := range *reads.applications {
+		if application == "" {
+			t.Fatalf("expected the pipeline to be read by its id only, got reads of %q", *reads.applications)
+		}
+	}
+	if imported.Get("application").(string) != "tf-unit-test" {
+		t.Fatalf("expected the pipeline imported by its id, got %v", imported.State())
+	}
+}
+
+func TestResourceSpinnakerPipelineMove(t *testing.T) {
+	type project struct {
+		application  string
+		applications []string
+	}
+
+	tcs := map[string]struct {
+		moveInProjects bool
+		projects       map[string]project
+	}{
+		"move in projects": {
+			moveInProjects: true,
+			projects: map[string]project{
+				"tf-unit-test-project": {application: "tf-unit-test-new", applications: []string{"tf-unit-test", "tf-unit-test-new"}},
+				"tf-unit-test-other":   {application: "tf-unit-test", applications: []string{"tf-unit-test"}},
+			},
+		},
+		// A project managed by a spinnaker_project is not rewritten behind it
+		"leave projects": {
+			projects: map[string]project{
+				"tf-unit-test-project": {application: "tf-unit-test", applications: []string{"tf-unit-test"}},
+				"tf-unit-test-other":   {application: "tf-unit-test", applications: []string{"tf-unit-test"}},
+			},
+		},
+	}
+
+	for n, tc := range tcs {
+		tc := tc
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			gate := fakegate.New()
+			meta := testUnitMeta(gate)
+			r := resourcePipeline()
+			d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+				"application":      "tf-unit-test",
+				"name":             "deploy",
+				"pipeline":         `{"stages": []}`,
+				"move_in_projects": tc.moveInProjects,
+			})
+			if diags := r.CreateContext(context.Background(), d, meta); diags.HasError() {
+				t.Fatalf("failed: %v", diags)
+			}
+			id := d.Id()
+
+			client := gate.Client()
+			for name, pipelineID := range map[string]string{"tf-unit-test-project": id, "tf-unit-test-other": "other"} {
+				err := client.CreateProject(api.UpsertApplicationTask{
+					"application": "spinnaker",
+					"job": []interface{}{map[string]interface{}{
+						"type": "upsertProject",
+						"project": map[string]interface{}{
+							"name": name,
+							"config": map[string]interface{}{
+								"applications":    []interface{}{"tf-unit-test"},
+								"pipelineConfigs": []interface{}{map[string]interface{}{"application": "tf-unit-test", "pipelineConfigId": pipelineID}},
+							},
+						},
+					}},
+				})
+				if err != nil {
+					t.Fatalf("failed: %v", err)
+				}
+			}
+
+			if err := d.Set("application", "tf-unit-test-new"); err != nil {
+				t.Fatalf("failed: %v", err)
+			}
+			if diags := r.UpdateContext(context.Background(), d, meta); diags.HasError() {
+				t.Fatalf("failed: %v", diags)
+			}
+
+			if pipelines := gate.Pipelines("tf-unit-test"); len(pipelines) != 0 {
+				t.Fatalf("expected the pipeline to leave its application, got %v", pipelines)
+			}
+			pipelines := gate.Pipelines("tf-unit-test-new")
+			if len(pipelines) != 1 || pipelines[0]["id"] != id || d.Id() != id {
+				t.Fatalf("expected the pipeline moved with its id, got %v", pipelines)
+			}
+
+			for name, want := range tc.projects {
+				var project projectRead
+				if err := client.GetProject(name, &project); err != nil {
+					t.Fatalf("failed: %v", err)
+				}
+				if got := project.Config.PipelineConfigs[0].Application; got != want.application {
+					t.Fatalf("expected the pipeline config of %s in %s, got %s", name, want.application, got)
+				}
+				if !reflect.DeepEqual(project.Config.Applications, want.applications) {
+					t.Fatalf("expected the applications of %s to be %v, got %v", name, want.applications, project.Config.Applications)
+				}
+			}
+		})
+	}
+}
+
+func TestPipelineDiffSuppressFuncIgnorePaths(t *testing.T) {
+	tcs := map[string]struct {
+		ignorePaths []interface{}
+		old         string
+		new         string
+		suppressed  bool
+	}{
+		"managed keys": {
+			old:        `{"id": "01ABC", "updateTs": "1", "stages": []}`,
+			new:        `{"stages": []}`,
+			suppressed: true,
+		},
+		"changed": {
+			old: `{"stages": [{"refId": "1", "type": "wait"}]}`,
+			new: `{"stages": [{"refId": "2", "type": "wait"}]}`,
+		},
+		"ignored": {
+			ignorePaths: []interface{}{"stages[*].refId", "triggers[*].id", "locked", "spelEvaluator"},
+			old:         `{"locked": {"ui": true}, "spelEvaluator": "v4", "stages": [{"refId": "1", "type": "wait"}], "triggers": [{"id": "a", "type": "git"}]}`,
+			new:         `{"stages": [{"refId": "2", "type": "wait"}], "triggers": [{"type": "git"}]}`,
+			suppressed:  true,
+		},
+		"ignored with other changes": {
+			ignorePaths: []interface{}{"stages[*].refId"},
+			old:         `{"stages": [{"refId": "1", "type": "wait"}]}`,
+			new:         `{"stages": [{"refId": "2", "type": "manualJudgment"}]}`,
+		},
+	}
+
+	for name, tc := range tcs {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			d := schema.TestResourceDataRaw(t, resourcePipeline().Schema, map[string]interface{}{
+				"application":  "tf-unit-test",
+				"name":         "deploy",
+				"pipeline":     tc.new,
+				"ignore_paths": tc.ignorePaths,
+			})
+
+			if got := pipelineDiffSuppressFunc("pipeline", tc.old, tc.new, d); got != tc.suppressed {
+				t.Fatalf("expected suppressed %v, got %v", tc.suppressed, got)
+			}
+		})
+	}
+}
+
+func TestResourceSpinnakerPipelinePlanChanges(t *testing.T) {
+	state := map[string]string{
+		"id":          "01ABC",
+		"application": "tf-unit-test",
+		"name":        "deploy",
+		"pipeline":    `{"stages":[{"refId":"1","timeout":300,"type":"wait"}]}`,
+		"pipeline_id": "01ABC",
+	}
+
+	tcs := map[string]struct {
+		pipeline    string
+		ignorePaths []interface{}
+		expected    []string
+	}{
+		"unchanged": {
+			pipeline: `{"stages": [{"type": "wait", "refId": "1", "timeout": 300}]}`,
+		},
+		"changed": {
+			pipeline: `{"stages": [{"type": "wait", "refId": "1", "timeout": 600}, {"type": "manualJudgment", "refId": "2"}]}`,
+			expected: []string{
+				"stages[0].timeout: 300 -> 600",
+				`stages[1]: added {"refId":"2","type":"manualJudgment"}`,
+			},
+		},
+		"ignored": {
+			pipeline:    `{"stages": [{"type": "wait", "refId": "2", "timeout": 300}]}`,
+			ignorePaths: []interface{}{"stages[*].refId"},
+		},
+	}
+
+	for name, tc := range tcs {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			r := resourcePipeline()
+			raw := map[string]interface{}{
+				"application": "tf-unit-test",
+				"name":        "deploy",
+				"pipeline":    tc.pipeline,
+			}
+			if tc.ignorePaths != nil {
+				raw["ignore_paths"] = tc.ignorePaths
+			}
+
+			b, err := json.Marshal(raw)
+			if err != nil {
+				t.Fatalf("failed: %v", err)
+			}
+			rawConfig, err := ctyjson.Unmarshal(b, r.CoreConfigSchema().ImpliedType())
+			if err != nil {
+				t.Fatalf("failed: %v", err)
+			}
+
+			diff, err := r.Diff(context.Background(), &terraform.InstanceState{ID: state["id"], Attributes: state, RawConfig: rawConfig}, terraform.NewResourceConfigRaw(raw), gateConfig{})
+			if err != nil {
+				t.Fatalf("failed: %v", err)
+			}
+
+			var got []string
+			if diff != nil {
+				for i := 0; ; i++ {
+					attr, ok := diff.Attributes[fmt.Sprintf("pipeline_changes.%d", i)]
+					if !ok {
+						break
+					}
+					got = append(got, attr.New)
+				}
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Fatalf("expected the changes %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestResourceSpinnakerPipelineApplyKeepsPlanChanges(t *testing.T) {
+	gate := fakegate.New()
+	meta := testUnitMeta(gate)
+	r := resourcePipeline()
+	d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"application": "tf-unit-test",
+		"name":        "deploy",
+		"pipeline":    `{"stages": [{"type": "wait", "refId": "1", "timeout": 300}]}`,
+	})
+	if diags := r.CreateContext(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("failed: %v", diags)
+	}
+
+	raw := map[string]interface{}{
+		"application": "tf-unit-test",
+		"name":        "deploy",
+		"pipeline":    `{"stages": [{"type": "wait", "refId": "1", "timeout": 600}]}`,
+	}
+	b, err := json.Marshal(raw)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	rawConfig, err := ctyjson.Unmarshal(b, r.CoreConfigSchema().ImpliedType())
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	state := d.State()
+	state.RawConfig = rawConfig
+
+	diff, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), meta)
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	applied, diags := r.Apply(context.Background(), state, diff, meta)
+	if diags.HasError() {
+		t.Fatalf("failed: %v", diags)
+	}
+
+	// Terraform expects the applied value of a computed attribute to be the
+	// planned one
+	if got := applied.Attributes["pipeline_changes.0"]; got != "stages[0].timeout: 300 -> 600" {
+		t.Fatalf("expected the planned changes in the state, got %v", applied.Attributes)
+	}
+}
+
+func TestResourceSpinnakerPipelineReadDrift(t *testing.T) {
+	tcs := map[string]struct {
+		pipeline    string
+		ignorePaths []interface{}
+		expected    []string
+	}{
+		"unchanged": {
+			pipeline: `{"stages": [{"type": "wait", "refId": "1", "timeout": 300}]}`,
+		},
+		"changed": {
+			pipeline: `{"stages": [{"type": "wait", "refId": "1", "timeout": 600}, {"type": "manualJudgment", "refId": "2"}]}`,
+			expected: []string{
+				"  stages[0].timeout: 300 -> 600",
+				`  stages[1]: added {"refId":"2","type":"manualJudgment"}`,
+			},
+		},
+		"ignored": {
+			pipeline:    `{"stages": [{"type": "wait", "refId": "2", "timeout": 300}]}`,
+			ignorePaths: []interface{}{"stages[*].refId"},
+		},
+	}
+
+	for name, tc := range tcs {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			gate := fakegate.New()
+			meta := testUnitMeta(gate)
+			r := resourcePipeline()
+			raw := map[string]interface{}{
+				"application": "tf-unit-test",
+				"name":        "deploy",
+				"pipeline":    `{"stages": [{"type": "wait", "refId": "1", "timeout": 300}]}`,
+			}
+			if tc.ignorePaths != nil {
+				raw["ignore_paths"] = tc.ignorePaths
+			}
+			d := schema.TestResourceDataRaw(t, r.Schema, raw)
+			if diags := r.CreateContext(context.Background(), d, meta); diags.HasError() || len(diags) != 0 {
+				t.Fatalf("expected the create to read the pipeline without warning, got %v", diags)
+			}
+
+			// Changed outside of Terraform
+			pipeline := map[string]interface{}{}
+			if err := json.Unmarshal([]byte(tc.pipeline), &pipeline); err != nil {
+				t.Fatalf("failed: %v", err)
+			}
+			pipeline["application"], pipeline["name"], pipeline["id"] = "tf-unit-test", "deploy", d.Id()
+			if err := gate.Client().UpdatePipeline(d.Id(), pipeline); err != nil {
+				t.Fatalf("failed: %v", err)
+			}
+
+			// Refreshed by the next plan, with a new provider and cache
+			diags := r.ReadContext(context.Background(), d, testUnitMeta(gate))
+			if diags.HasError() {
+				t.Fatalf("failed: %v", diags)
+			}
+			if len(tc.expected) == 0 {
+				if len(diags) != 0 {
+					t.Fatalf("expected no warning, got %v", diags)
+				}
+				return
+			}
+
+			if len(diags) != 1 || diags[0].Severity != diag.Warning || diags[0].Summary != "Pipeline changed outside of Terraform" {
+				t.Fatalf("expected a warning about the changes, got %v", diags)
+			}
+			got := strings.Split(diags[0].Detail, "\n")[1:]
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Fatalf("expected the changes %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestResourceSpinnakerPipelineCreateDocument(t *testing.T) {
+	tcs := map[string]struct {
+		pipeline string
+	}{
+		"omitted":     {pipeline: `{"stages": []}`},
+		"overwritten": {pipeline: `{"application": "other", "name": "other", "stages": []}`},
+	}
+
+	for name, tc := range tcs {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			gate := fakegate.New()
+			r := resourcePipeline()
+			d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+				"application": "tf-unit-test",
+				"name":        "deploy",
+				"pipeline":    tc.pipeline,
+			})
+
+			if diags := r.CreateContext(context.Background(), d, testUnitMeta(gate)); diags.HasError() {
+				t.Fatalf("failed: %v", diags)
+			}
+			pipelines := gate.Pipelines("tf-unit-test")
+			if len(pipelines) != 1 || pipelines[0]["name"] != "deploy" {
+				t.Fatalf("expected the pipeline saved under the application and name of the resource, got %v", pipelines)
+			}
+			if other := gate.Pipelines("other"); len(other) != 0 {
+				t.Fatalf("expected no pipeline saved under the application of the JSON, got %v", other)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package spinnaker
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 
@@ -22,9 +23,10 @@ func resourceSpinnakerProject() *schema.Resource {
 				ValidateFunc: validateSpinnakerApplicationName,
 			},
 			"email": {
-				Description: "Email of the owner",
+				Description: "Email of the owner, defaults to the default_owner_email of the provider",
 				Type:        schema.TypeString,
-				Required:    true,
+				Optional:    true,
+				Computed:    true,
 			},
 			"config": {
 				Description: "Configuration of the project",
@@ -35,6 +37,7 @@ func resourceSpinnakerProject() *schema.Resource {
 				},
 			},
 		},
+		CustomizeDiff: customizeDiffDefault("email", "default_owner_email"),
 		CreateContext: resourceSpinnakerProjectCreate,
 		ReadContext:   resourceSpinnakerProjectRead,
 		UpdateContext: resourceSpinnakerProjectUpdate,
@@ -42,6 +45,11 @@ func resourceSpinnakerProject() *schema.Resource {
 		Importer: &schema.ResourceImporter{
 			StateContext: resourceSpinnakerProjectImport,
 		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(10 * time.Minute),
+			Update: schema.DefaultTimeout(10 * time.Minute),
+			Delete: schema.DefaultTimeout(10 * time.Minute),
+		},
 	}
 }
 
@@ -71,7 +79,7 @@ type PipelineConfig struct {
 
 func resourceSpinnakerProjectCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	clientConfig := meta.(gateConfig)
-	client := clientConfig.client
+	client := clientConfig.client.WithContext(ctx)
 	projectName := d.Get("name").(string)
 
 	task, err := api.NewUpsertApplicationTask(d)
@@ -79,8 +87,8 @@ func resourceSpinnakerProjectCreate(ctx context.Context, d *schema.ResourceData,
 		return diag.FromErr(err)
 	}
 
-	if err := api.CreateProject(client, task); err != nil {
-		return diag.FromErr(err)
+	if err := client.CreateProject(task); err != nil {
+		return diagFromErr(err)
 	}
 
 	d.SetId(projectName)
@@ -90,14 +98,14 @@ func resourceSpinnakerProjectCreate(ctx context.Context, d *schema.ResourceData,
 func resourceSpinnakerProjectRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	clientConfig := meta.(gateConfig)
 	var diags diag.Diagnostics
-	client := clientConfig.client
+	client := clientConfig.client.WithContext(ctx)
 	projectName := d.Get("name").(string)
 	if projectName == "" {
 		projectName = d.Id()
 	}
 
 	app := &projectRead{}
-	if err := api.GetProject(client, projectName, app); err != nil {
+	if err := client.GetProject(projectName, app); err != nil {
 		return diag.FromErr(err)
 	}
 
@@ -125,27 +133,36 @@ func resourceSpinnakerProjectRead(ctx context.Context, d *schema.ResourceData, m
 
 func resourceSpinnakerProjectUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	clientConfig := meta.(gateConfig)
-	client := clientConfig.client
-	task, err := api.NewCreateApplicationTask(d)
-	if err != nil {
+	client := clientConfig.client.WithContext(ctx)
+
+	// The project is updated by its Front50 id, it is renamed when name changes
+	project := &projectRead{}
+	if err := client.GetProject(d.Id(), project); err != nil {
 		return diag.FromErr(err)
 	}
 
-	if err := api.CreateApplication(client, task); err != nil {
+	task, err := api.NewUpdateProjectTask(d, project.ID)
+	if err != nil {
 		return diag.FromErr(err)
 	}
+
+	if err := client.CreateProject(task); err != nil {
+		return diagFromErr(err)
+	}
+
+	d.SetId(d.Get("name").(string))
 	return resourceSpinnakerProjectRead(ctx, d, meta)
 }
 
 func resourceSpinnakerProjectDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	clientConfig := meta.(gateConfig)
 	var diags diag.Diagnostics
-	client := clientConfig.client
+	client := clientConfig.client.WithContext(ctx)
 	id := d.Id()
 	appName := d.Get("name").(string)
 
-	if err := api.DeleteProject(client, id, appName); err != nil {
-		return diag.FromErr(err)
+	if err := client.DeleteProject(id, appName); err != nil {
+		return diagFromErr(err)
 	}
 
 	d.SetId("")
@@ -1,15 +1,20 @@
 package spinnaker
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"net/http"
 	"strconv"
 	"testing"
 	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+
+	"github.com/himanhsugusain/terraform-provider-spinnaker/spinnaker/api"
+	"github.com/himanhsugusain/terraform-provider-spinnaker/spinnaker/fakegate"
 )
 
 func TestAccResourceSourceSpinnakerProject_basic(t *testing.T) {
@@ -19,7 +24,7 @@ func TestAccResourceSourceSpinnakerProject_basic(t *testing.T) {
 	resource.ParallelTest(t, resource.TestCase{
 		PreCheck:     func() { testAccPreCheck(t) },
 		Providers:    testAccProviders,
-		CheckDestroy: testAccCheckSpinnakerApplicatioDestroy(t, resourceName),
+		CheckDestroy: testAccCheckSpinnakerApplicatioDestroy(t, testAccProvider, resourceName),
 		Steps: []resource.TestStep{
 			{
 				Config: testAccSpinnakerProject_basic(rName),
@@ -46,15 +51,10 @@ func testAccCheckSpinnakerProjectExists(n string) resource.TestCheckFunc {
 		}
 		client := testAccProvider.Meta().(gateConfig).client
 		err := resource.Retry(1*time.Minute, func() *resource.RetryError {
-			_, resp, err := client.ProjectControllerApi.GetUsingGET1(client.Context, rs.Primary.ID)
-			if resp != nil {
-				if resp.StatusCode == http.StatusNotFound {
-					return resource.RetryableError(fmt.Errorf("application does not exit"))
-				} else if resp.StatusCode != http.StatusOK {
-					return resource.NonRetryableError(fmt.Errorf("encountered an error getting application, status code: %d", resp.StatusCode))
+			if err := client.GetProject(rs.Primary.ID, &projectRead{}); err != nil {
+				if errors.Is(err, api.ErrCodeNoSuchEntityException) {
+					return resource.RetryableError(fmt.Errorf("project does not exit"))
 				}
-			}
-			if err != nil {
 				return resource.NonRetryableError(err)
 			}
 			return nil
@@ -74,3 +74,72 @@ resource "spinnaker_project" "my_proj" {
 }
 `, rName)
 }
+
+func TestResourceSpinnakerProject_unit(t *testing.T) {
+	gate := fakegate.New()
+	testUnitTest(t, gate, testUnitCheckSpinnakerProjectDeleted(gate, "tf-unit-test"),
+		resource.TestStep{
+			Config: testAccSpinnakerProject_basic("tf-unit-test"),
+			Check:  resource.TestCheckResourceAttr("spinnaker_project.my_proj", "email", "acceptance@test.com"),
+		},
+	)
+}
+
+func testUnitCheckSpinnakerProjectDeleted(gate *fakegate.Gate, projectName string) resource.TestCheckFunc {
+	return testUnitCheckDeleted("project "+projectName, func() error {
+		return gate.Client().GetProject(projectName, &projectRead{})
+	})
+}
+
+func TestResourceSpinnakerProjectCRUD(t *testing.T) {
+	gate := fakegate.New()
+	meta := testUnitMeta(gate)
+	r := resourceSpinnakerProject()
+	d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"name":  "tf-unit-test",
+		"email": "acceptance@test.com",
+	})
+
+	if diags := r.CreateContext(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("failed: %v", diags)
+	}
+	if d.Id() != "tf-unit-test" || d.Get("email").(string) != "acceptance@test.com" {
+		t.Fatalf("unexpected state after create: %v", d.State())
+	}
+	created := &projectRead{}
+	if err := gate.Client().GetProject("tf-unit-test", created); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+
+	if err := d.Set("name", "tf-unit-test-renamed"); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	if err := d.Set("email", "owner@test.com"); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	if diags := r.UpdateContext(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("failed: %v", diags)
+	}
+	for _, task := range gate.Tasks() {
+		if task.Type != "upsertProject" {
+			t.Fatalf("expected only upsertProject tasks, got %+v", task)
+		}
+	}
+	updated := &projectRead{}
+	if err := gate.Client().GetProject("tf-unit-test-renamed", updated); err != nil || updated.ID != created.ID || updated.Email != "owner@test.com" {
+		t.Fatalf("expected the project updated in place, got %+v: %v", updated, err)
+	}
+	if err := testUnitCheckSpinnakerProjectDeleted(gate, "tf-unit-test")(nil); err != nil {
+		t.Fatalf("expected the project renamed, got %v", err)
+	}
+	if d.Id() != "tf-unit-test-renamed" {
+		t.Fatalf("unexpected state after update: %v", d.State())
+	}
+
+	if diags := r.DeleteContext(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("failed: %v", diags)
+	}
+	if err := testUnitCheckSpinnakerProjectDeleted(gate, "tf-unit-test-renamed")(nil); err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+}